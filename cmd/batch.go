@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// readBatchFile reads talk URLs or titles from path, one per line.
+// Blank lines and lines starting with # are ignored.
+func readBatchFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			warnf("failed to close batch file: %v\n", err)
+		}
+	}()
+
+	var targets []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+	return targets, nil
+}
+
+// runBatch downloads every target, continuing past failures, and prints a summary.
+// With a manifest, targets it records as done are skipped and the status of
+// every target is recorded in it. When ctx's deadline passes, the targets not
+// finished yet are listed as remaining and stay pending in the manifest.
+func runBatch(ctx context.Context, p parser.TalkParser, d *downloader.Downloader, targets []string, m *manifest) (*batchResult, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no talks to download in batch file")
+	}
+
+	result := &batchResult{
+		Talks:  []*talkResult{},
+		Failed: []batchFailure{},
+	}
+	record := func(target, status string, err error) {
+		if m == nil {
+			return
+		}
+		if err := m.set(target, status, err); err != nil {
+			warnf("%v\n", err)
+		}
+	}
+	for _, target := range targets {
+		if m != nil && !redownload() && m.done(target) {
+			continue
+		}
+		record(target, statusPending, nil)
+	}
+
+	finished := len(targets) // targets attempted or skipped before the deadline
+	for i, target := range targets {
+		infof("\n[%d/%d] %s\n", i+1, len(targets), target)
+		if m != nil && !redownload() && m.done(target) {
+			infof("Skipping: downloaded in a previous run (see %s)\n", manifestFilename)
+			result.Skipped = append(result.Skipped, target)
+			continue
+		}
+		var err error
+		if parser.IsPlaylistURL(target) {
+			var playlist *batchResult
+			playlist, err = downloadPlaylist(ctx, p, d, target)
+			if playlist != nil {
+				result.Talks = append(result.Talks, playlist.Talks...)
+			}
+		} else {
+			var talk *talkResult
+			talk, err = downloadTalk(ctx, p, d, target)
+			if talk != nil {
+				result.Talks = append(result.Talks, talk)
+			}
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// Out of time: the unfinished targets stay pending
+			rest := targets[i:]
+			if err == nil {
+				record(target, statusDone, nil)
+				rest = targets[i+1:]
+			}
+			finished = len(targets) - len(rest)
+			for _, target := range rest {
+				if m == nil || redownload() || !m.done(target) {
+					result.Remaining = append(result.Remaining, target)
+				}
+			}
+			break
+		}
+		if ctx.Err() != nil {
+			// Interrupted: the target stays pending for the next run
+			return result, err
+		}
+		if err != nil {
+			infof("Error: %v\n", err)
+			result.Failed = append(result.Failed, batchFailure{Target: target, Error: err.Error()})
+			record(target, statusFailed, err)
+		} else {
+			record(target, statusDone, nil)
+		}
+	}
+
+	failed, skipped, remaining := len(result.Failed), len(result.Skipped), len(result.Remaining)
+	if remaining > 0 {
+		infof("\nBatch deadline reached: %d succeeded, %d skipped, %d failed, %d remaining\n", finished-failed-skipped, skipped, failed, remaining)
+		infof("Remaining:\n")
+		for _, target := range result.Remaining {
+			infof("  %s\n", target)
+		}
+		for _, f := range result.Failed {
+			infof("Failed: %s: %s\n", f.Target, f.Error)
+		}
+		return result, fmt.Errorf("batch deadline reached: %d of %d talks remaining", remaining, len(targets))
+	}
+	if skipped > 0 {
+		infof("\nBatch completed: %d succeeded, %d skipped, %d failed\n", len(targets)-failed-skipped, skipped, failed)
+	} else {
+		infof("\nBatch completed: %d succeeded, %d failed\n", len(targets)-failed, failed)
+	}
+	if failed == 0 {
+		return result, nil
+	}
+
+	infof("Failed:\n")
+	for _, f := range result.Failed {
+		infof("  %s: %s\n", f.Target, f.Error)
+	}
+	return result, fmt.Errorf("%d of %d downloads failed", failed, len(targets))
+}
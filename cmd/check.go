@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check <url or title>",
+	Short: "Check that the download links of a talk still work",
+	Long: `Resolve a talk and send a HEAD request to each of its video, audio and
+subtitle URLs without downloading them, reporting the status of every URL.
+Exits with an error when a link is dead, e.g. an expired CDN URL. For example:
+tedfetch check https://www.ted.com/talks/brene_brown_the_power_of_vulnerability`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCheck,
+}
+
+// newCheckParser returns the parser used by the check command. Tests replace
+// it to avoid the network.
+var newCheckParser = func() (parser.TalkParser, error) {
+	return newParser()
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+// checkedLink is a download URL of a talk with the result of its HEAD request
+type checkedLink struct {
+	name   string // e.g. "video 720p" or "subtitle en"
+	url    string
+	status string // status line, or the request error
+	ok     bool
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	p, err := newCheckParser()
+	if err != nil {
+		return err
+	}
+
+	var talk *parser.Talk
+	if strings.HasPrefix(args[0], "http") {
+		talk, err = p.ParseURLContext(cmd.Context(), args[0])
+	} else {
+		talk, err = p.ParseTalkDetailsContext(cmd.Context(), args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse talk details: %w", err)
+	}
+
+	links := talkLinks(talk)
+	if len(links) == 0 {
+		return fmt.Errorf("no download links found for this talk")
+	}
+	// The downloader only sends HEAD requests, nothing is written to its
+	// directory
+	d, err := downloader.NewWithClient(".", httpClient)
+	if err != nil {
+		return fmt.Errorf("failed to create downloader: %w", err)
+	}
+	d.SetUserAgent(userAgent)
+	d.SetLogger(logger)
+
+	dead := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSTATUS\tURL")
+	for i := range links {
+		link := &links[i]
+		_, err := d.RemoteSizeContext(cmd.Context(), link.url)
+		var statusErr *downloader.StatusError
+		switch {
+		case cmd.Context().Err() != nil:
+			return fmt.Errorf("check cancelled: %w", cmd.Context().Err())
+		case errors.As(err, &statusErr):
+			link.status = statusErr.Status
+		case err != nil:
+			link.status = err.Error()
+		default:
+			link.status = "200 OK"
+			link.ok = true
+		}
+		if !link.ok {
+			dead++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", link.name, link.status, link.url)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Println("flush output error:", err)
+	}
+
+	if dead > 0 {
+		return fmt.Errorf("%d of %d download links are dead", dead, len(links))
+	}
+	return nil
+}
+
+// talkLinks lists the download URLs of a talk: videos from the highest
+// quality, then the audio file and the subtitles by language
+func talkLinks(talk *parser.Talk) []checkedLink {
+	var links []checkedLink
+	urls := videoURLs(talk)
+	for _, quality := range sortedQualities(urls) {
+		links = append(links, checkedLink{name: "video " + quality, url: urls[quality]})
+	}
+	if talk.AudioURL != "" {
+		links = append(links, checkedLink{name: "audio", url: talk.AudioURL})
+	}
+	for _, lang := range sortedKeys(talk.SubtitleURLs) {
+		if url := talk.SubtitleURLs[lang]; url != "" {
+			links = append(links, checkedLink{name: "subtitle " + lang, url: url})
+		}
+	}
+	return links
+}
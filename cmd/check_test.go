@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.URL.Path == "/expired.mp4" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	talk := &parser.Talk{
+		URL:          "https://www.ted.com/talks/test_slug",
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4", "1080p": server.URL + "/expired.mp4"},
+		AudioURL:     server.URL + "/audio.mp3",
+		SubtitleURLs: map[string]string{"en": server.URL + "/en.srt"},
+	}
+	saved := newCheckParser
+	newCheckParser = func() (parser.TalkParser, error) {
+		return &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}, nil
+	}
+	defer func() { newCheckParser = saved }()
+
+	// Capture stdout
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	os.Stdout = w
+	rootCmd.SetArgs([]string{"check", talk.URL})
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	checkCmd.SetContext(context.Background())
+	err = rootCmd.ExecuteContext(context.Background())
+	os.Stdout = stdout
+	assert.NoError(t, w.Close())
+	assert.EqualError(t, err, "1 of 4 download links are dead")
+
+	output, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	for _, want := range []string{
+		"video 1080p  403 Forbidden  " + server.URL + "/expired.mp4",
+		"video 720p   200 OK         " + server.URL + "/720p.mp4",
+		"audio        200 OK",
+		"subtitle en  200 OK",
+	} {
+		assert.Contains(t, string(output), want)
+	}
+	// Nothing is downloaded
+	assert.Equal(t, []string{"HEAD", "HEAD", "HEAD", "HEAD"}, methods)
+}
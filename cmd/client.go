@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"golang.org/x/net/proxy"
+)
+
+// newHTTPClient returns the client shared by the parser and the downloader,
+// configured by --proxy and --timeout. Without --proxy, HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY from the environment apply. Unresponsive servers
+// time out like with the downloader's default client, even without --timeout.
+func newHTTPClient() (*http.Client, error) {
+	transport := downloader.NewTransport()
+	if proxyURL != "" {
+		if err := setProxy(transport, proxyURL); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// newParser returns a parser configured by the shared flags
+func newParser() (*parser.Parser, error) {
+	p := parser.NewWithClient(httpClient)
+	p.UserAgent = userAgent
+	p.RequestDelay = requestDelay
+	p.BaseURL = baseURL
+	p.GraphqlURL = graphqlURL
+	p.SetLogger(logger)
+	if cacheDir != "" {
+		cache, err := parser.NewFileCache(cacheDir, cacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		p.Cache = cache
+	}
+	return p, nil
+}
+
+// newTalkParser returns the parser used by the download command, configured by
+// the shared flags and --language. Tests replace it to avoid the network.
+var newTalkParser = func() (parser.TalkParser, error) {
+	p, err := newParser()
+	if err != nil {
+		return nil, err
+	}
+	p.Language = language
+	return p, nil
+}
+
+// validateURL checks that the value of flag is an absolute http or https URL
+func validateURL(flag, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid %s %q: expected an http or https URL like https://host/path", flag, rawURL)
+	}
+	return nil
+}
+
+// setProxy routes transport through the proxy at rawURL.
+// http, https, socks5 and socks5h schemes are supported.
+func setProxy(transport *http.Transport, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid --proxy %q: expected scheme://host:port", rawURL)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(u)
+	case "socks5", "socks5h":
+		// Connecting to the SOCKS5 server times out like a direct connection
+		dialer, err := proxy.FromURL(u, &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+		if err != nil {
+			return fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return fmt.Errorf("SOCKS5 dialer does not support contexts")
+		}
+		transport.Proxy = nil
+		transport.DialContext = contextDialer.DialContext
+	default:
+		return fmt.Errorf("unsupported --proxy scheme %q (use http, https, socks5 or socks5h)", u.Scheme)
+	}
+	return nil
+}
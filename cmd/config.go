@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	// configName is the config file looked up, with a .yaml (or .yml, .json,
+	// .toml) extension, in the working directory and then the home directory
+	configName = ".tedfetch"
+	// envPrefix prefixes the environment variables setting flags, e.g.
+	// TEDFETCH_QUALITY for --quality
+	envPrefix = "TEDFETCH"
+)
+
+// configFile is the --config flag
+var configFile string
+
+// loadConfig sets every flag of cmd that was not given on the command line
+// from the environment or, failing that, from the config file. Keys are flag
+// names, e.g. "quality" or "user-agent"; environment variables spell them
+// TEDFETCH_QUALITY or TEDFETCH_USER_AGENT. The flags stay unchanged in the
+// cobra sense, like defaults. It returns the path of the config file read,
+// or "" if there is none.
+func loadConfig(cmd *cobra.Command) (string, error) {
+	v := viper.New()
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+	} else {
+		v.SetConfigName(configName)
+		v.AddConfigPath(".")
+		if home, err := os.UserHomeDir(); err == nil {
+			v.AddConfigPath(home)
+		}
+	}
+	if err := v.ReadInConfig(); err != nil {
+		// Without --config the file is optional
+		var notFound viper.ConfigFileNotFoundError
+		if configFile != "" || !errors.As(err, &notFound) {
+			return "", fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	var errs []error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "config" || f.Name == "help" || !v.IsSet(f.Name) {
+			return
+		}
+		value := configValue(v.Get(f.Name))
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q in config or environment: %w", f.Name, value, err))
+		}
+	})
+	return v.ConfigFileUsed(), errors.Join(errs...)
+}
+
+// configValue formats a config value as it would be given on the command
+// line; lists become comma-separated, e.g. for --quality or --subtitle
+func configValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownload_Config(t *testing.T) {
+	server, _ := newFileServer(t)
+	talk := &parser.Talk{
+		URL:  "https://www.ted.com/talks/test_slug",
+		Slug: "test_slug",
+		VideoURLs: map[string]string{
+			"480p":  server.URL + "/480p.mp4",
+			"720p":  server.URL + "/720p.mp4",
+			"1080p": server.URL + "/1080p.mp4",
+		},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+
+	// The config file in the working directory is found without --config
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	config := "output: " + dir + "\nquality:\n  - 480p\n  - 1080p\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".tedfetch.yaml"), []byte(config), 0644))
+
+	assert.NoError(t, runDownloadCmd(t, p, talk.URL))
+	assert.FileExists(t, filepath.Join(dir, "test_slug", "480p.mp4"))
+	assert.FileExists(t, filepath.Join(dir, "test_slug", "1080p.mp4"))
+	assert.NoFileExists(t, filepath.Join(dir, "test_slug", "720p.mp4"))
+	// Like defaults, config values don't count as given on the command line
+	assert.False(t, downloadCmd.Flags().Changed("quality"))
+	assert.False(t, downloadCmd.Flags().Changed("output"))
+
+	// The environment overrides the config file, and flags override both
+	envDir, flagDir := t.TempDir(), t.TempDir()
+	t.Setenv("TEDFETCH_QUALITY", "720p")
+	t.Setenv("TEDFETCH_OUTPUT", envDir)
+	assert.NoError(t, runDownloadCmd(t, p, talk.URL))
+	assert.FileExists(t, filepath.Join(envDir, "test_slug", "720p.mp4"))
+	assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", flagDir, "-q", "480p"))
+	assert.FileExists(t, filepath.Join(flagDir, "test_slug", "480p.mp4"))
+	assert.NoFileExists(t, filepath.Join(flagDir, "test_slug", "720p.mp4"))
+
+	// Invalid values and a missing --config file are reported
+	t.Setenv("TEDFETCH_CONCURRENCY", "many")
+	err = runDownloadCmd(t, p, talk.URL)
+	assert.ErrorContains(t, err, `invalid concurrency "many" in config or environment`)
+	err = runDownloadCmd(t, p, talk.URL, "--config", filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read config file")
+}
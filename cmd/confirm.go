@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+var (
+	// stdinIsTerminal reports whether the confirmation can be asked for;
+	// tests replace it together with promptInput
+	stdinIsTerminal = func() bool { return isTerminal(os.Stdin) }
+	// promptInput is where the answer to a confirmation is read from
+	promptInput io.Reader = os.Stdin
+)
+
+// knownSize returns the size of the talk's media file at url as reported by
+// the talk page, or -1 if the page didn't give it
+func knownSize(talk *parser.Talk, url string) int64 {
+	for _, format := range talk.VideoFormats {
+		if format.URL == url && format.Size > 0 {
+			return format.Size
+		}
+	}
+	return -1
+}
+
+// confirmDownload prints the size of the media file of a talk and, when it
+// is larger than --confirm-above, asks before downloading it. Nothing is
+// asked with --yes or when stdin isn't a terminal, and then the size is only
+// printed if the talk page gave it, without a HEAD request.
+func confirmDownload(ctx context.Context, d *downloader.Downloader, talk *parser.Talk, url, name string) error {
+	ask := !assumeYes && confirmLimit > 0 && stdinIsTerminal()
+	size := knownSize(talk, url)
+	if size < 0 && ask {
+		var err error
+		size, err = d.RemoteSizeContext(ctx, url)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			warnf("failed to get the size of the %s: %v\n", name, err)
+			return nil
+		}
+	}
+	if size < 0 {
+		return nil
+	}
+	infof("Size: %s\n", formatBytes(size))
+
+	if !ask || size <= confirmLimit {
+		return nil
+	}
+	ok, err := askYesNo(promptInput, os.Stderr, fmt.Sprintf("The %s is a %s download, continue?", name, formatBytes(size)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("download of %s cancelled", talk.Title)
+	}
+	return nil
+}
+
+// askYesNo prints question to w and reads the answer from r; anything but
+// "y" or "yes" counts as no
+func askYesNo(r io.Reader, w io.Writer, question string) (bool, error) {
+	fmt.Fprintf(w, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
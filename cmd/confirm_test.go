@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmDownload(t *testing.T) {
+	var heads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt32(&heads, 1)
+		}
+		w.Header().Set("Content-Length", "2048")
+	}))
+	defer server.Close()
+
+	d, err := downloader.NewWithClient(t.TempDir(), server.Client())
+	assert.NoError(t, err)
+	talk := &parser.Talk{Title: "Test Title"}
+	url := server.URL + "/720p.mp4"
+
+	savedYes, savedLimit := assumeYes, confirmLimit
+	savedTerminal, savedInput := stdinIsTerminal, promptInput
+	t.Cleanup(func() {
+		assumeYes, confirmLimit = savedYes, savedLimit
+		stdinIsTerminal, promptInput = savedTerminal, savedInput
+	})
+	confirmLimit = 1024
+
+	tests := []struct {
+		name     string
+		yes      bool
+		terminal bool
+		answer   string
+		heads    int32
+		wantErr  bool
+		output   string
+	}{
+		{name: "yes", yes: true, terminal: true},
+		{name: "not a terminal", terminal: false},
+		{name: "answered yes", terminal: true, answer: "y\n", heads: 1, output: "Size: 2.0 KiB\n"},
+		{name: "answered no", terminal: true, answer: "n\n", heads: 1, wantErr: true, output: "Size: 2.0 KiB\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&heads, 0)
+			assumeYes = tt.yes
+			stdinIsTerminal = func() bool { return tt.terminal }
+			promptInput = strings.NewReader(tt.answer)
+
+			var err error
+			output := captureStdout(t, func() {
+				err = confirmDownload(context.Background(), d, talk, url, "video")
+			})
+			if tt.wantErr {
+				assert.EqualError(t, err, "download of Test Title cancelled")
+			} else {
+				assert.NoError(t, err)
+			}
+			// Without a prompt the size isn't worth a HEAD request
+			assert.Equal(t, tt.heads, atomic.LoadInt32(&heads))
+			assert.Equal(t, tt.output, output)
+		})
+	}
+
+	// A size from the talk page is printed without a request
+	atomic.StoreInt32(&heads, 0)
+	assumeYes = true
+	talk.VideoFormats = []parser.VideoFormat{{Quality: "720p", URL: url, Size: 4096}}
+	output := captureStdout(t, func() {
+		assert.NoError(t, confirmDownload(context.Background(), d, talk, url, "video"))
+	})
+	assert.Equal(t, "Size: 4.0 KiB\n", output)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&heads))
+}
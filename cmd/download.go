@@ -1,12 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/baiyutang/tedfetch/internal/downloader"
 	"github.com/baiyutang/tedfetch/internal/parser"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -16,96 +25,783 @@ var (
 		Short: "Download TED talk videos and subtitles",
 		Long: `Download TED talk videos and subtitles. For example:
 tedfetch download "The power of vulnerability" --quality 720p
-tedfetch download https://www.ted.com/talks/ariel_ekblaw_how_to_build_in_space_for_life_on_earth --quality 720p --subtitle zh-CN`,
+tedfetch download https://www.ted.com/talks/ariel_ekblaw_how_to_build_in_space_for_life_on_earth --quality 720p --subtitle zh-CN
+tedfetch download --batch urls.txt
+tedfetch download https://www.ted.com/playlists/171/the_most_popular_talks_of_all`,
 		RunE: runDownload,
 	}
 
 	// Flags
-	quality  string
-	subtitle string
-	output   string
+	quality     string
+	subtitle    string
+	output      string
+	audioOnly   bool
+	subOnly     bool
+	limitRate   string
+	listFormats bool
+	checksum    bool
+	force       bool
+	batchFile   string
+	jsonOutput  bool
+	metadata    bool
+	nfo         bool
+	embedSubs   string
+	outputTmpl  string
+	filename    string
+	flat        bool
+	withRelated bool
+	concurrency int
+	retries     int
+	dryRun      bool
+	language    string
+	assumeYes   bool
+	confirmSize string
+	// batchTimeout bounds the whole run of a batch or playlist
+	batchTimeout time.Duration
+	// minDuration and maxDuration skip talks outside this length
+	minDuration, maxDuration time.Duration
+
+	// confirmLimit is --confirm-above in bytes
+	confirmLimit int64
+
+	// Per-type overrides of --force, see overwritePolicy
+	overwriteVideo, noOverwriteVideo       bool
+	overwriteSubtitle, noOverwriteSubtitle bool
 )
 
 func init() {
 	rootCmd.AddCommand(downloadCmd)
 
 	// Add flags
-	downloadCmd.Flags().StringVarP(&quality, "quality", "q", "720p", "Video quality (720p, 1080p)")
-	downloadCmd.Flags().StringVarP(&subtitle, "subtitle", "s", "", "Subtitle language code (e.g., en, zh-CN). Leave empty to skip subtitle download")
-	downloadCmd.Flags().StringVarP(&output, "output", "o", ".", "Output directory")
+	addDownloadFlags(downloadCmd.Flags())
+	downloadCmd.Flags().StringVar(&filename, "filename", "", "Save a single talk's video (or audio) to exactly this path; subtitles are saved next to it as <name>.<lang>.srt")
+	downloadCmd.Flags().StringVar(&batchFile, "batch", "", "File with one talk URL or title per line to download")
+}
+
+// addDownloadFlags adds the flags choosing what to download of a talk and
+// how, shared by the download and speaker commands, to flags. Each command
+// gets its own flags, bound to the same variables.
+func addDownloadFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&quality, "quality", "q", "720p", "Video quality (360p, 720p, 1080p), or best/worst for the highest/lowest available; a comma-separated list like 720p,1080p downloads each available one")
+	flags.StringVarP(&subtitle, "subtitle", "s", "", "Comma-separated subtitle language codes (e.g., en,zh-CN), or all. Leave empty to skip subtitle download")
+	flags.StringVar(&language, "language", parser.DefaultLanguage, "Language of the talk title and description (e.g., es, zh-cn)")
+	flags.StringVarP(&output, "output", "o", ".", "Output directory")
+	flags.StringVar(&outputTmpl, "output-template", "", "Go template for file paths inside the output directory, e.g. '{{.Speaker}}/{{.Title}}-{{.Quality}}' (fields: Title, Speaker, Slug, Quality, Lang, Date)")
+	flags.BoolVar(&flat, "flat", false, "Save every file directly in the output directory as <slug>-<file>, e.g. <slug>-720p.mp4, instead of a folder per talk")
+	flags.BoolVar(&audioOnly, "audio-only", false, "Download only the audio track instead of the video")
+	flags.BoolVar(&subOnly, "subtitle-only", false, "Download only the subtitles given with --subtitle, without the video")
+	flags.BoolVar(&dryRun, "dry-run", false, "Print the files that would be downloaded, with their URLs and paths, without downloading them")
+	flags.BoolVar(&listFormats, "list-formats", false, "List available video qualities and subtitle languages without downloading")
+	flags.BoolVar(&checksum, "checksum", false, "Write a SHA-256 <file>.sha256 next to each download and skip files that still match it")
+	flags.BoolVarP(&force, "force", "f", false, "Download files again even if they are already complete")
+	flags.BoolVar(&overwriteVideo, "overwrite-video", false, "Download videos again even if they are already complete")
+	flags.BoolVar(&noOverwriteVideo, "no-overwrite-video", false, "Keep complete videos, even with --force")
+	flags.BoolVar(&overwriteSubtitle, "overwrite-subtitle", false, "Download subtitles again even if they are already complete, e.g. to get TED's corrections")
+	flags.BoolVar(&noOverwriteSubtitle, "no-overwrite-subtitle", false, "Keep complete subtitles, even with --force")
+	flags.StringVar(&embedSubs, "embed-subtitles", "", "Mux the video and the given downloaded subtitle languages (comma-separated, or all) into an .mkv with ffmpeg")
+	flags.Lookup("embed-subtitles").NoOptDefVal = "all"
+	flags.IntVar(&concurrency, "concurrency", 1, "Number of files of a talk (video and subtitles) to download at the same time")
+	flags.IntVar(&retries, "retries", 3, "Number of attempts for each file before giving up; 1 fails on the first error")
+	flags.BoolVar(&withRelated, "with-related", false, "Also download the talk's related talks (up to 6)")
+	flags.BoolVar(&metadata, "metadata", false, "Write the talk's metadata to metadata.json in its download directory")
+	flags.BoolVar(&nfo, "nfo", false, "Write a Kodi/Jellyfin .nfo file with the talk's metadata next to the video")
+	flags.StringVar(&confirmSize, "confirm-above", "500M", "Ask before downloading a video or audio file larger than this, with optional K/M/G suffix; 0 never asks")
+	flags.BoolVarP(&assumeYes, "yes", "y", false, "Download large files without asking for confirmation")
+	flags.BoolVar(&jsonOutput, "json", false, "Print a JSON summary of the downloaded files instead of progress messages; errors are printed as JSON to stderr")
+	flags.DurationVar(&minDuration, "min-duration", 0, "Skip talks shorter than this, e.g. 5m (0 means no limit)")
+	flags.DurationVar(&maxDuration, "max-duration", 0, "Skip talks longer than this, e.g. 20m (0 means no limit)")
+	flags.DurationVar(&batchTimeout, "batch-timeout", 0, "Stop a --batch or playlist download after this long, e.g. 2h, and list the talks left for the next run (0 means no limit)")
+	flags.StringVar(&limitRate, "limit-rate", "", "Maximum download speed in bytes per second, with optional K/M/G suffix (e.g., 500K, 2M)")
 }
 
 func runDownload(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 {
+	if len(args) == 0 && batchFile == "" {
 		return fmt.Errorf("please provide a talk title or URL")
 	}
+	if filename != "" {
+		if batchFile != "" {
+			return fmt.Errorf("--filename cannot be combined with --batch")
+		}
+		if parser.IsPlaylistURL(args[0]) {
+			return fmt.Errorf("--filename cannot be used with a playlist")
+		}
+		if len(splitQualities(quality)) > 1 {
+			return fmt.Errorf("--filename cannot be used with several --quality values")
+		}
+	}
+	p, d, err := setupDownload(cmd)
+	if err != nil {
+		return err
+	}
+	err = download(cmd.Context(), p, d, args)
+	if err != nil && cmd.Context().Err() != nil {
+		// Interrupted with Ctrl+C: Execute prints just this and exits with code 130
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return fmt.Errorf("download cancelled: %w", cmd.Context().Err())
+	}
+	return err
+}
+
+// download fetches the talks, playlists or batch file named by args and the flags
+func download(ctx context.Context, p parser.TalkParser, d *downloader.Downloader, args []string) error {
+	// Retries and downloads of a batch share its deadline
+	if batchTimeout > 0 && (batchFile != "" || parser.IsPlaylistURL(args[0])) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, batchTimeout)
+		defer cancel()
+	}
+
+	if batchFile != "" {
+		targets, err := readBatchFile(batchFile)
+		if err != nil {
+			return err
+		}
+		var m *manifest
+		if !dryRun {
+			if m, err = loadManifest(output); err != nil {
+				return err
+			}
+		}
+		result, err := runBatch(ctx, p, d, append(args, targets...), m)
+		if jsonOutput && result != nil {
+			printJSON(result)
+		}
+		return err
+	}
+
+	if parser.IsPlaylistURL(args[0]) {
+		result, err := downloadPlaylist(ctx, p, d, args[0])
+		if jsonOutput && result != nil {
+			printJSON(result)
+		}
+		return err
+	}
+
+	result, err := downloadTalk(ctx, p, d, args[0])
+	if err != nil {
+		return err
+	}
+	if jsonOutput && result != nil {
+		printJSON(result)
+	}
+	return nil
+}
+
+// setupDownload validates the flags shared by the download and speaker
+// commands and returns the parser and the downloader they configure
+func setupDownload(cmd *cobra.Command) (parser.TalkParser, *downloader.Downloader, error) {
+	if jsonOutput {
+		// Errors are reported as JSON by Execute
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+	}
+
+	if concurrency < 1 {
+		return nil, nil, fmt.Errorf("invalid --concurrency %d: must be at least 1", concurrency)
+	}
+	if retries < 1 {
+		return nil, nil, fmt.Errorf("invalid --retries %d: must be at least 1", retries)
+	}
+	if batchTimeout < 0 {
+		return nil, nil, fmt.Errorf("invalid --batch-timeout %s: must not be negative", batchTimeout)
+	}
+	if minDuration < 0 {
+		return nil, nil, fmt.Errorf("invalid --min-duration %s: must not be negative", minDuration)
+	}
+	if maxDuration < 0 {
+		return nil, nil, fmt.Errorf("invalid --max-duration %s: must not be negative", maxDuration)
+	}
+	if maxDuration > 0 && minDuration > maxDuration {
+		return nil, nil, fmt.Errorf("--min-duration %s is longer than --max-duration %s", minDuration, maxDuration)
+	}
+	var err error
+	if confirmLimit, err = parseByteSize(confirmSize); err != nil {
+		return nil, nil, fmt.Errorf("invalid --confirm-above: %w", err)
+	}
+	if overwriteVideo && noOverwriteVideo {
+		return nil, nil, fmt.Errorf("--overwrite-video cannot be combined with --no-overwrite-video")
+	}
+	if overwriteSubtitle && noOverwriteSubtitle {
+		return nil, nil, fmt.Errorf("--overwrite-subtitle cannot be combined with --no-overwrite-subtitle")
+	}
+	if flat && outputTmpl != "" {
+		return nil, nil, fmt.Errorf("--flat cannot be combined with --output-template")
+	}
+	if subOnly {
+		switch {
+		case subtitle == "":
+			return nil, nil, fmt.Errorf("--subtitle-only needs the languages to download with --subtitle")
+		case audioOnly:
+			return nil, nil, fmt.Errorf("--subtitle-only cannot be combined with --audio-only")
+		case embedSubs != "":
+			return nil, nil, fmt.Errorf("--subtitle-only cannot be combined with --embed-subtitles")
+		case nfo:
+			return nil, nil, fmt.Errorf("--subtitle-only cannot be combined with --nfo")
+		}
+	}
+	if embedSubs != "" {
+		if audioOnly {
+			return nil, nil, fmt.Errorf("--embed-subtitles cannot be combined with --audio-only")
+		}
+		if _, err := findFFmpeg(); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Create parser
-	p := parser.New()
+	p, err := newTalkParser()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Create downloader
-	d, err := downloader.New(output)
+	d, err := downloader.NewWithClient(output, httpClient)
 	if err != nil {
-		return fmt.Errorf("failed to create downloader: %w", err)
+		return nil, nil, fmt.Errorf("failed to create downloader: %w", err)
+	}
+	if limitRate != "" {
+		rate, err := parseByteSize(limitRate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid --limit-rate: %w", err)
+		}
+		d.SetRateLimit(rate)
+	}
+	d.SetUserAgent(userAgent)
+	d.SetLogger(logger)
+	if quiet {
+		// No progress bars
+		d.SetProgressHandler(func(downloaded, total int64) {})
+	}
+	d.SetMaxRetries(retries)
+	d.SetChecksum(checksum)
+	d.SetOverwrite(force)
+	d.SetOverwriteFor(downloader.JobVideo, overwritePolicy(overwriteVideo, noOverwriteVideo))
+	d.SetOverwriteFor(downloader.JobSubtitle, overwritePolicy(overwriteSubtitle, noOverwriteSubtitle))
+	if err := d.SetNameTemplate(outputTmpl); err != nil {
+		return nil, nil, fmt.Errorf("invalid --output-template: %w", err)
+	}
+	if flat {
+		d.SetLayout(downloader.LayoutFlat)
+	}
+	return p, d, nil
+}
+
+// downloadTalk parses a talk title or URL and downloads it according to the
+// flags, followed by its related talks with --with-related.
+// It returns nil without downloading when --list-formats is set.
+func downloadTalk(ctx context.Context, p parser.TalkParser, d *downloader.Downloader, target string) (*talkResult, error) {
+	talk, result, err := downloadSingleTalk(ctx, p, d, target, filename)
+	if err != nil || result == nil || !withRelated {
+		return result, err
 	}
 
+	for i, slug := range talk.RelatedSlugs {
+		infof("\nRelated talk [%d/%d]: %s\n", i+1, len(talk.RelatedSlugs), slug)
+		_, related, err := downloadSingleTalk(ctx, p, d, p.TalkURL(slug), "")
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if err != nil {
+			// A missing related talk shouldn't fail the one that was asked for
+			warnf("failed to download related talk %s: %v\n", slug, err)
+			continue
+		}
+		result.Related = append(result.Related, related)
+	}
+	return result, nil
+}
+
+// downloadSingleTalk parses a talk title or URL and downloads just that talk.
+// A non-empty path replaces the templated path of the video or audio file.
+func downloadSingleTalk(ctx context.Context, p parser.TalkParser, d *downloader.Downloader, target, path string) (*parser.Talk, *talkResult, error) {
 	// Parse talk details
 	var talk *parser.Talk
-	var slug string
-	if strings.HasPrefix(args[0], "http") {
-		talk, err = p.ParseURL(args[0])
-		slug = extractSlug(args[0])
+	var err error
+	if strings.HasPrefix(target, "http") {
+		talk, err = p.ParseURLContext(ctx, target)
 	} else {
-		talk, err = p.ParseTalkDetails(args[0])
-		slug = extractSlug(talk.URL)
+		talk, err = p.ParseTalkDetailsContext(ctx, target)
+	}
+	if errors.Is(err, parser.ErrGeoBlocked) || errors.Is(err, parser.ErrConsentWall) {
+		return nil, nil, fmt.Errorf("failed to parse talk details: %w (try --proxy with a server in another region)", err)
 	}
 	if err != nil {
-		return fmt.Errorf("failed to parse talk details: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse talk details: %w", err)
+	}
+	if language != parser.DefaultLanguage && len(talk.SubtitleLanguages) > 0 && !hasLanguage(talk, language) {
+		warnf("language %s not available for this talk, title and description may be in English\n", language)
+	}
+	fields := downloader.NameFields{
+		Title:   strings.TrimSpace(talk.Title),
+		Speaker: strings.TrimSpace(talk.Speaker),
+		Slug:    talkSlug(talk),
+		Date:    talk.PublishedDate,
+	}
+
+	if listFormats {
+		d.FillVideoSizesContext(ctx, talk)
+		if jsonOutput {
+			return talk, &talkResult{Title: talk.Title, Speaker: talk.Speaker, URL: talk.URL, Formats: newFormatsResult(talk)}, nil
+		}
+		printFormats(talk)
+		return talk, nil, nil
+	}
+	if reason := durationFilter(talk); reason != "" {
+		infof("Skipping: %s\n", reason)
+		logger.Debug("skipping talk outside the duration range", "talk", talk.URL, "duration", talk.Duration)
+		return talk, nil, nil
+	}
+
+	// Check everything that was asked for before fetching any bytes
+	sel, err := resolveSelection(talk)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &talkResult{
+		Title:   talk.Title,
+		Speaker: talk.Speaker,
+		URL:     talk.URL,
+	}
+
+	// Collect the media files and the requested subtitles
+	var mediaJobs, subJobs []downloader.DownloadJob
+	var mediaNames, subNames []string // describe each job in messages
+	switch {
+	case subOnly:
+	case audioOnly:
+		audioFields := fields
+		audioFields.Quality = "audio"
+		audioPath, err := talkPath(d, audioFields, "audio.mp3", path)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Without a URL the downloader picks the source, like DownloadAudio
+		if sel.audioSource == downloader.AudioFromVideo {
+			infof("Audio source: extracted with ffmpeg from the smallest video, TED offers no audio file for this talk\n")
+			mediaNames = append(mediaNames, "audio (from the smallest video)")
+		} else {
+			infof("Audio source: TED audio file\n")
+			mediaNames = append(mediaNames, "audio")
+		}
+		result.AudioSource = string(sel.audioSource)
+		mediaJobs = append(mediaJobs, downloader.DownloadJob{Filename: audioPath, Type: downloader.JobAudio, Talk: talk})
+	default:
+		result.Quality = sel.videos[0].quality
+
+		for _, v := range sel.videos {
+			videoFields := fields
+			videoFields.Quality = v.quality
+			videoPath, err := talkPath(d, videoFields, fmt.Sprintf("%s.mp4", v.quality), path)
+			if err != nil {
+				return nil, nil, err
+			}
+			mediaJobs = append(mediaJobs, downloader.DownloadJob{URL: v.url, Filename: videoPath, Type: downloader.JobVideo, Talk: talk})
+			mediaNames = append(mediaNames, fmt.Sprintf("video (%s)", v.quality))
+		}
+	}
+
+	for _, lang := range sel.langs {
+		subtitleURL := talk.SubtitleURLs[lang]
+
+		subtitleFields := fields
+		subtitleFields.Lang = lang
+		subtitlePath, err := talkPath(d, subtitleFields, fmt.Sprintf("%s.srt", lang), path)
+		if err != nil {
+			return nil, nil, err
+		}
+		job := downloader.DownloadJob{URL: subtitleURL, Filename: subtitlePath, Type: downloader.JobSubtitle, Talk: talk}
+		if talk.ID != "" && !dryRun {
+			// TED's subtitles API has the captions of every language the
+			// talk was translated to; the file URL is only a fallback
+			content, err := p.GetSubtitleContext(ctx, talk.ID, lang)
+			switch {
+			case err == nil:
+				job.Content = content
+			case subtitleURL == "" || ctx.Err() != nil:
+				return nil, nil, fmt.Errorf("failed to get subtitle (%s): %w", lang, err)
+			default:
+				warnf("failed to get subtitle (%s) from the subtitles API, downloading the file instead: %v\n", lang, err)
+			}
+		}
+		subJobs = append(subJobs, job)
+		subNames = append(subNames, fmt.Sprintf("subtitle (%s)", languageLabel(talk, lang)))
+	}
+	jobs := append(mediaJobs, subJobs...)
+	names := append(mediaNames, subNames...)
+
+	if dryRun {
+		result, err := planResult(d, fields, result, mediaJobs, subJobs, names, sel)
+		return talk, result, err
+	}
+
+	for i, job := range mediaJobs {
+		if err := confirmDownload(ctx, d, talk, jobURL(job, sel), mediaNames[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var errs []error
+	for i, err := range downloadJobs(ctx, d, jobs, names) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to download %s: %w", names[i], err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	fillFiles(result, sel, mediaJobs, subJobs, func(job downloader.DownloadJob) fileResult {
+		return *newFileResult(d, job.Filename)
+	})
+	for _, lang := range sel.langs {
+		sub := result.Subtitles[lang]
+		infof("Subtitle: %s\n", sub.Path)
+		if sub.SHA256 != "" {
+			infof("SHA-256: %s\n", sub.SHA256)
+		}
+	}
+
+	if embedSubs != "" {
+		if err := embedSubtitles(ctx, talk, result); err != nil {
+			return nil, nil, fmt.Errorf("failed to embed subtitles: %w", err)
+		}
 	}
 
-	// Get video URL for requested quality
-	videoURL, ok := talk.VideoURLs[quality]
-	if !ok {
-		return fmt.Errorf("video quality %s not available", quality)
+	if metadata {
+		metadataPath, err := d.TalkPath(fields, metadataFilename)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := writeMetadata(metadataPath, talk); err != nil {
+			return nil, nil, err
+		}
+		result.Metadata = metadataPath
+		infof("Metadata: %s\n", metadataPath)
 	}
 
-	// Download video
-	fmt.Printf("Downloading video (%s)...\n", quality)
-	videoPath := d.GetDownloadPath(slug, fmt.Sprintf("%s.mp4", quality))
-	if err := d.DownloadVideo(videoURL, videoPath); err != nil {
-		return fmt.Errorf("failed to download video: %w", err)
+	infof("\nDownload completed!\n")
+	if subOnly {
+		return talk, result, nil
 	}
 
-	// Download subtitle if requested
-	if subtitle != "" {
-		subtitleURL, ok := talk.SubtitleURLs[subtitle]
-		if !ok {
-			return fmt.Errorf("subtitle language %s not available", subtitle)
+	files := mediaFiles(result, sel)
+	if nfo {
+		for _, media := range files {
+			path := nfoPath(media.file.Path)
+			if err := writeNFO(path, talk); err != nil {
+				return nil, nil, err
+			}
+			setNFO(result, media.quality, path)
+			infof("NFO: %s\n", path)
 		}
+	}
 
-		fmt.Printf("Downloading subtitle (%s)...\n", subtitle)
-		subtitlePath := d.GetDownloadPath(slug, fmt.Sprintf("%s.srt", subtitle))
-		if err := d.DownloadSubtitle(subtitleURL, subtitlePath); err != nil {
-			return fmt.Errorf("failed to download subtitle: %w", err)
+	for _, media := range files {
+		infof("%s: %s\n", media.label, media.file.Path)
+		if media.file.SHA256 != "" {
+			infof("SHA-256: %s\n", media.file.SHA256)
 		}
-		fmt.Printf("Subtitle: %s\n", subtitlePath)
 	}
 
-	fmt.Printf("\nDownload completed!\n")
-	fmt.Printf("Video: %s\n", videoPath)
+	return talk, result, nil
+}
 
+// fillFiles sets the media files and subtitles of result from their jobs,
+// each described by file. The video of the first --quality is also
+// result.Video.
+func fillFiles(result *talkResult, sel *selection, mediaJobs, subJobs []downloader.DownloadJob, file func(downloader.DownloadJob) fileResult) {
+	for i, job := range mediaJobs {
+		media := file(job)
+		if job.Type == downloader.JobAudio {
+			result.Audio = &media
+			continue
+		}
+		if i == 0 {
+			result.Video = &media
+		}
+		if len(sel.videos) > 1 {
+			if result.Videos == nil {
+				result.Videos = make(map[string]fileResult)
+			}
+			result.Videos[sel.videos[i].quality] = media
+		}
+	}
+	for i, lang := range sel.langs {
+		if result.Subtitles == nil {
+			result.Subtitles = make(map[string]fileResult)
+		}
+		result.Subtitles[lang] = file(subJobs[i])
+	}
+}
+
+// mediaFile is a downloaded video or audio file of a talk
+type mediaFile struct {
+	label   string // "Audio", "Video", or "Video (720p)" with several qualities
+	quality string // set with several qualities
+	file    fileResult
+}
+
+// mediaFiles returns the media files of result, the videos in the order of
+// --quality
+func mediaFiles(result *talkResult, sel *selection) []mediaFile {
+	switch {
+	case result.Audio != nil:
+		return []mediaFile{{label: "Audio", file: *result.Audio}}
+	case len(result.Videos) > 0:
+		files := make([]mediaFile, 0, len(sel.videos))
+		for _, v := range sel.videos {
+			files = append(files, mediaFile{label: fmt.Sprintf("Video (%s)", v.quality), quality: v.quality, file: result.Videos[v.quality]})
+		}
+		return files
+	case result.Video != nil:
+		return []mediaFile{{label: "Video", file: *result.Video}}
+	}
 	return nil
 }
 
-// extractSlug extracts the slug from a TED talk URL
-func extractSlug(url string) string {
-	parts := strings.Split(strings.SplitN(url, "?", 2)[0], "/")
-	slug := ""
-	for i := len(parts) - 1; i >= 0; i-- {
-		if parts[i] != "" {
-			slug = parts[i]
-			break
+// setNFO records the .nfo written for the video of quality, or for the only
+// media file when quality is empty
+func setNFO(result *talkResult, quality, path string) {
+	if quality == "" || quality == result.Quality {
+		result.NFO = path
+	}
+	if quality != "" {
+		if result.NFOs == nil {
+			result.NFOs = make(map[string]string)
 		}
+		result.NFOs[quality] = path
+	}
+}
+
+// talkPath returns where to save a file of a talk: the templated path, or
+// with --filename that path for the media file and <name>.<lang>.srt next to
+// it for subtitles. A --filename without extension gets the one of format.
+func talkPath(d *downloader.Downloader, fields downloader.NameFields, format, path string) (string, error) {
+	if path == "" {
+		return d.TalkPath(fields, format)
+	}
+	ext := filepath.Ext(format)
+	if filepath.Ext(path) == "" {
+		path += ext
+	}
+	if fields.Lang == "" {
+		return path, nil
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	return fmt.Sprintf("%s.%s%s", base, fields.Lang, ext), nil
+}
+
+// jobURL returns the URL job downloads, which for --audio-only the
+// downloader picks itself
+func jobURL(job downloader.DownloadJob, sel *selection) string {
+	if job.Type == downloader.JobAudio && job.URL == "" {
+		return sel.audioURL
+	}
+	return job.URL
+}
+
+// planResult prints the files a download would fetch and fills result with
+// them, for --dry-run
+func planResult(d *downloader.Downloader, fields downloader.NameFields, result *talkResult, mediaJobs, subJobs []downloader.DownloadJob, names []string, sel *selection) (*talkResult, error) {
+	result.DryRun = true
+	infof("Dry run, nothing will be downloaded:\n")
+	for i, job := range append(mediaJobs, subJobs...) {
+		infof("  %s: %s\n    -> %s\n", names[i], jobURL(job, sel), job.Filename)
+	}
+	fillFiles(result, sel, mediaJobs, subJobs, func(job downloader.DownloadJob) fileResult {
+		return fileResult{Path: job.Filename, URL: jobURL(job, sel)}
+	})
+
+	if metadata {
+		metadataPath, err := d.TalkPath(fields, metadataFilename)
+		if err != nil {
+			return nil, err
+		}
+		result.Metadata = metadataPath
+		infof("  metadata\n    -> %s\n", metadataPath)
+	}
+	if nfo {
+		for _, media := range mediaFiles(result, sel) {
+			path := nfoPath(media.file.Path)
+			setNFO(result, media.quality, path)
+			infof("  nfo\n    -> %s\n", path)
+		}
+	}
+	if embedSubs != "" && len(sel.langs) > 0 {
+		infof("  subtitles would be embedded into an .mkv with ffmpeg\n")
+	}
+	return result, nil
+}
+
+// downloadJobs fetches the files of a talk, up to --concurrency at a time,
+// and returns one error (or nil) per job
+func downloadJobs(ctx context.Context, d *downloader.Downloader, jobs []downloader.DownloadJob, names []string) []error {
+	if concurrency > 1 && len(jobs) > 1 {
+		infof("Downloading %s...\n", strings.Join(names, ", "))
+		return d.DownloadBatchContext(ctx, jobs, concurrency)
+	}
+
+	// One at a time, with a progress bar per file
+	errs := make([]error, len(jobs))
+	for i, job := range jobs {
+		infof("Downloading %s...\n", names[i])
+		errs[i] = d.DownloadBatchContext(ctx, []downloader.DownloadJob{job}, 1)[0]
+	}
+	return errs
+}
+
+// newFileResult describes the downloaded file at path
+func newFileResult(d *downloader.Downloader, path string) *fileResult {
+	result := &fileResult{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		result.Size = info.Size()
+	}
+	if checksum {
+		result.SHA256 = d.Checksum(path)
+	}
+	return result
+}
+
+// downloadPlaylist downloads every talk of a playlist into a folder named after it
+func downloadPlaylist(ctx context.Context, p parser.TalkParser, d *downloader.Downloader, url string) (*batchResult, error) {
+	playlist, err := p.ParsePlaylistContext(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse playlist: %w", err)
+	}
+
+	name := playlist.Title
+	if name == "" {
+		name = path.Base(strings.TrimSuffix(strings.SplitN(url, "?", 2)[0], "/"))
+	}
+	sub, err := d.Subdir(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create playlist directory: %w", err)
+	}
+
+	infof("Playlist: %s (%d talks)\n", name, len(playlist.Talks))
+	urls := make([]string, 0, len(playlist.Talks))
+	for _, talk := range playlist.Talks {
+		urls = append(urls, talk.URL)
+	}
+	result, err := runBatch(ctx, p, sub, urls, nil)
+	if result != nil {
+		result.Playlist = name
+	}
+	return result, err
+}
+
+// subtitleLanguages expands the --subtitle value into a list of language codes.
+// It accepts a comma-separated list, or "all" for every language of the talk.
+// Codes differing only in case, like zh-CN and zh-cn, are listed once.
+func subtitleLanguages(talk *parser.Talk, value string) []string {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, lang := range strings.Split(value, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" || seen[strings.ToLower(lang)] {
+			continue
+		}
+		if lang == "all" {
+			all := make([]string, 0, len(talk.SubtitleURLs))
+			for code := range talk.SubtitleURLs {
+				all = append(all, code)
+			}
+			sort.Strings(all)
+			return all
+		}
+		seen[strings.ToLower(lang)] = true
+		langs = append(langs, lang)
+	}
+	return langs
+}
+
+// hasLanguage reports whether the talk is available in lang, judging by its
+// subtitle languages
+func hasLanguage(talk *parser.Talk, lang string) bool {
+	for code := range talk.SubtitleLanguages {
+		if strings.EqualFold(code, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseByteSize parses a byte count with an optional K, M or G suffix (powers of 1024)
+func parseByteSize(s string) (int64, error) {
+	value := strings.TrimSpace(strings.ToUpper(s))
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// overwritePolicy returns whether complete files of a type are downloaded
+// again, given its --overwrite-X and --no-overwrite-X flags; without either
+// --force decides
+func overwritePolicy(overwrite, noOverwrite bool) bool {
+	switch {
+	case noOverwrite:
+		return false
+	case overwrite:
+		return true
+	}
+	return force
+}
+
+// redownload reports whether any complete file is downloaded again, so
+// talks a batch already finished can't be skipped
+func redownload() bool {
+	return force || overwriteVideo || overwriteSubtitle
+}
+
+// durationFilter returns why talk is skipped by --min-duration or
+// --max-duration, or "" if it is downloaded. Talks of unknown length are
+// downloaded.
+func durationFilter(talk *parser.Talk) string {
+	if minDuration == 0 && maxDuration == 0 {
+		return ""
+	}
+	length, ok := talk.Length()
+	switch {
+	case !ok:
+		return ""
+	case length < minDuration:
+		return fmt.Sprintf("%s long, shorter than --min-duration %s", talk.Duration, minDuration)
+	case maxDuration > 0 && length > maxDuration:
+		return fmt.Sprintf("%s long, longer than --max-duration %s", talk.Duration, maxDuration)
+	}
+	return ""
+}
+
+// talkSlug returns the name of a talk's folder. TED's slugs are unique while
+// its titles are reused by different speakers, so the title, prefixed with
+// the speaker, is only used for a talk without a slug or a talk URL.
+func talkSlug(talk *parser.Talk) string {
+	if talk.Slug != "" {
+		return talk.Slug
+	}
+	if slug, err := parser.SlugFromURL(talk.URL); err == nil {
+		return slug
+	}
+	name := strings.TrimSpace(talk.Title)
+	if speaker := strings.TrimSpace(talk.Speaker); speaker != "" {
+		name = speaker + " - " + name
 	}
-	return slug
+	return name
 }
@@ -0,0 +1,691 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeParser serves talks from memory instead of TED
+type fakeParser struct {
+	talks map[string]*parser.Talk // by URL or title
+}
+
+func (f *fakeParser) ParseURLContext(ctx context.Context, url string) (*parser.Talk, error) {
+	if talk, ok := f.talks[url]; ok {
+		return talk, nil
+	}
+	return nil, parser.ErrTalkNotFound
+}
+
+func (f *fakeParser) ParseTalkDetailsContext(ctx context.Context, title string) (*parser.Talk, error) {
+	return f.ParseURLContext(ctx, title)
+}
+
+func (f *fakeParser) ParseTopicContext(ctx context.Context, topic string, limit int) ([]parser.Talk, error) {
+	return nil, nil
+}
+
+func (f *fakeParser) ParsePlaylistContext(ctx context.Context, url string) (*parser.Playlist, error) {
+	return nil, parser.ErrInvalidURL
+}
+
+func (f *fakeParser) SearchBySpeakerContext(ctx context.Context, name string, limit int) ([]parser.Talk, error) {
+	// In key order, so that limit keeps the same talks, and each talk once
+	// like the parser
+	keys := make([]string, 0, len(f.talks))
+	for key := range f.talks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var talks []parser.Talk
+	seen := make(map[*parser.Talk]bool)
+	for _, key := range keys {
+		if talk := f.talks[key]; talk.Speaker == name && !seen[talk] && len(talks) < limit {
+			seen[talk] = true
+			talks = append(talks, *talk)
+		}
+	}
+	return talks, nil
+}
+
+func (f *fakeParser) GetSubtitleContext(ctx context.Context, videoID, lang string) ([]byte, error) {
+	return nil, parser.ErrSubtitleNotFound
+}
+
+func (f *fakeParser) TalkURL(slug string) string {
+	return "https://www.ted.com/talks/" + slug
+}
+
+// newFileServer serves "content" for every path and counts the requests
+func newFileServer(t *testing.T) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte("content"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// runDownloadCmd runs "tedfetch download args..." against p with every
+// flag reset to its default first
+func runDownloadCmd(t *testing.T, p parser.TalkParser, args ...string) error {
+	return runDownloadCmdContext(t, context.Background(), p, args...)
+}
+
+// runDownloadCmdContext is like runDownloadCmd but cancels the command with ctx
+func runDownloadCmdContext(t *testing.T, ctx context.Context, p parser.TalkParser, args ...string) error {
+	return runCmd(t, ctx, downloadCmd, p, args...)
+}
+
+// inheritedEnv is the environment the tests were started with
+var inheritedEnv = os.Environ()
+
+// runCmd runs cmd with args and every flag at its default, fetching talks
+// from p. A config file in the home directory and TEDFETCH_* variables of
+// the environment the tests were started with are ignored, those the test
+// set itself are not.
+func runCmd(t *testing.T, ctx context.Context, cmd *cobra.Command, p parser.TalkParser, args ...string) error {
+	t.Setenv("HOME", t.TempDir())
+	for _, env := range inheritedEnv {
+		key, value, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(key, envPrefix+"_") && os.Getenv(key) == value {
+			t.Setenv(key, "")
+			assert.NoError(t, os.Unsetenv(key))
+		}
+	}
+	reset := func(f *pflag.Flag) {
+		_ = f.Value.Set(f.DefValue)
+		f.Changed = false
+	}
+	// The download and speaker flags share their variables, so both are reset
+	rootCmd.PersistentFlags().VisitAll(reset)
+	downloadCmd.Flags().VisitAll(reset)
+	speakerCmd.Flags().VisitAll(reset)
+	saved := newTalkParser
+	newTalkParser = func() (parser.TalkParser, error) { return p, nil }
+	t.Cleanup(func() { newTalkParser = saved })
+
+	rootCmd.SetArgs(append([]string{cmd.Name()}, args...))
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	// cobra only hands the context to a subcommand that has none yet
+	cmd.SetContext(ctx)
+	return rootCmd.ExecuteContext(ctx)
+}
+
+func TestDownload_FakeParser(t *testing.T) {
+	server, _ := newFileServer(t)
+	talk := &parser.Talk{
+		Title:        "Test Title",
+		Speaker:      "Test Speaker",
+		URL:          "https://www.ted.com/talks/test_slug",
+		Slug:         "test_slug",
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4"},
+		SubtitleURLs: map[string]string{"en": server.URL + "/en.srt", "zh-cn": server.URL + "/zh-cn.srt"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+
+	err := runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle", "zh-CN")
+	assert.NoError(t, err)
+	for _, name := range []string{"720p.mp4", "zh-cn.srt"} {
+		content, err := os.ReadFile(filepath.Join(dir, "Test Speaker", "test_slug", name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, "content", string(content), name)
+	}
+}
+
+func TestDownload_UnavailableSelection(t *testing.T) {
+	server, requests := newFileServer(t)
+	talk := &parser.Talk{
+		URL:          "https://www.ted.com/talks/test_slug",
+		Slug:         "test_slug",
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4"},
+		SubtitleURLs: map[string]string{"en": server.URL + "/en.srt"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+
+	// Both problems are reported before anything is fetched
+	err := runDownloadCmd(t, p, talk.URL, "--output", t.TempDir(), "--quality", "1080p", "--subtitle", "fr")
+	assert.ErrorContains(t, err, "video quality 1080p not available")
+	assert.ErrorContains(t, err, "subtitle language fr not available (available: en)")
+	assert.Zero(t, atomic.LoadInt32(requests))
+}
+
+func TestDownload_MultipleQualities(t *testing.T) {
+	server, _ := newFileServer(t)
+	talk := &parser.Talk{
+		URL:  "https://www.ted.com/talks/test_slug",
+		Slug: "test_slug",
+		VideoURLs: map[string]string{
+			"720p":  server.URL + "/720p.mp4",
+			"1080p": server.URL + "/1080p.mp4",
+		},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+
+	// Unavailable qualities are skipped, and a quality asked for twice is downloaded once
+	err := runDownloadCmd(t, p, talk.URL, "--output", dir, "--quality", "720p, 480p,1080p,best")
+	assert.NoError(t, err)
+	entries, err := os.ReadDir(filepath.Join(dir, "test_slug"))
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"1080p.mp4", "720p.mp4"}, names)
+
+	// --json lists every video, and --nfo writes one next to each
+	dir = t.TempDir()
+	talkDir := filepath.Join(dir, "test_slug")
+	output := captureStdout(t, func() {
+		assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", dir, "--quality", "1080p,720p", "--nfo", "--json"))
+	})
+	var result talkResult
+	assert.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.Equal(t, "1080p", result.Quality)
+	assert.Equal(t, map[string]fileResult{
+		"720p":  {Path: filepath.Join(talkDir, "720p.mp4"), Size: 7},
+		"1080p": {Path: filepath.Join(talkDir, "1080p.mp4"), Size: 7},
+	}, result.Videos)
+	assert.Equal(t, &fileResult{Path: filepath.Join(talkDir, "1080p.mp4"), Size: 7}, result.Video)
+	assert.Equal(t, map[string]string{
+		"720p":  filepath.Join(talkDir, "720p.nfo"),
+		"1080p": filepath.Join(talkDir, "1080p.nfo"),
+	}, result.NFOs)
+	assert.Equal(t, filepath.Join(talkDir, "1080p.nfo"), result.NFO)
+	assert.FileExists(t, filepath.Join(talkDir, "720p.nfo"))
+	assert.FileExists(t, filepath.Join(talkDir, "1080p.nfo"))
+
+	err = runDownloadCmd(t, p, talk.URL, "--output", dir, "--quality", "360p,480p")
+	assert.ErrorContains(t, err, "none of the video qualities 360p, 480p is available")
+
+	err = runDownloadCmd(t, p, talk.URL, "--output", dir, "--quality", "720p,1080p", "--filename", "talk.mp4")
+	assert.EqualError(t, err, "--filename cannot be used with several --quality values")
+}
+
+func TestDownload_SameTitleDifferentSpeakers(t *testing.T) {
+	server, _ := newFileServer(t)
+	fromURL := &parser.Talk{
+		Title:     "Same title",
+		Speaker:   "John Doe",
+		URL:       "https://www.ted.com/talks/john_doe_same_title",
+		VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4"},
+	}
+	withoutURL := &parser.Talk{
+		Title:     "Same title",
+		Speaker:   "Jane Smith",
+		VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{fromURL.URL: fromURL, "jane": withoutURL}}
+	dir := t.TempDir()
+
+	// Talks without a slug are named after their URL, or speaker and title,
+	// in a folder named after their speaker
+	assert.NoError(t, runDownloadCmd(t, p, fromURL.URL, "--output", dir))
+	assert.NoError(t, runDownloadCmd(t, p, "jane", "--output", dir))
+	assert.FileExists(t, filepath.Join(dir, "John Doe", "john_doe_same_title", "720p.mp4"))
+	assert.FileExists(t, filepath.Join(dir, "Jane Smith", "Jane Smith - Same title", "720p.mp4"))
+
+	// Talks with a slug never share a folder, whatever their title
+	first := &parser.Talk{
+		Title:     "Same title",
+		Speaker:   "John Doe",
+		URL:       "https://www.ted.com/talks/first",
+		Slug:      "john_doe_same_title",
+		VideoURLs: map[string]string{"720p": server.URL + "/first.mp4"},
+	}
+	second := &parser.Talk{
+		Title:     "Same title",
+		Speaker:   "Jane Smith",
+		URL:       "https://www.ted.com/talks/second",
+		Slug:      "jane_smith_same_title",
+		VideoURLs: map[string]string{"720p": server.URL + "/second.mp4"},
+	}
+	p = &fakeParser{talks: map[string]*parser.Talk{first.URL: first, second.URL: second}}
+	dir = t.TempDir()
+	for _, talk := range []*parser.Talk{first, second} {
+		output := captureStdout(t, func() {
+			assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", dir, "--json"))
+		})
+		var result talkResult
+		assert.NoError(t, json.Unmarshal([]byte(output), &result))
+		assert.Equal(t, filepath.Join(dir, talk.Speaker, talk.Slug, "720p.mp4"), result.Video.Path)
+	}
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.FileExists(t, filepath.Join(dir, "John Doe", "john_doe_same_title", "720p.mp4"))
+	assert.FileExists(t, filepath.Join(dir, "Jane Smith", "jane_smith_same_title", "720p.mp4"))
+
+	// The speaker keeps apart talks with the same title and slug
+	first.Slug, second.Slug = "same_title", "same_title"
+	dir = t.TempDir()
+	for _, talk := range []*parser.Talk{first, second} {
+		assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", dir))
+	}
+	assert.FileExists(t, filepath.Join(dir, "John Doe", "same_title", "720p.mp4"))
+	assert.FileExists(t, filepath.Join(dir, "Jane Smith", "same_title", "720p.mp4"))
+}
+
+func TestDownload_FlagValidation(t *testing.T) {
+	p := &fakeParser{}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{}, "please provide a talk title or URL"},
+		{[]string{"talk", "--concurrency", "0"}, "invalid --concurrency 0"},
+		{[]string{"talk", "--retries", "0"}, "invalid --retries 0"},
+		{[]string{"talk", "--filename", "talk.mp4", "--batch", "urls.txt"}, "--filename cannot be combined with --batch"},
+		{[]string{"talk", "--base-url", "www.ted.com"}, `invalid --base-url "www.ted.com"`},
+		{[]string{"talk", "--graphql-url", "ftp://mirror/graphql"}, `invalid --graphql-url "ftp://mirror/graphql"`},
+		{[]string{"missing talk"}, "talk not found"},
+	}
+	for _, tt := range tests {
+		err := runDownloadCmd(t, p, tt.args...)
+		assert.ErrorContains(t, err, tt.want, tt.args)
+	}
+}
+
+func TestDownload_SubtitleOnly(t *testing.T) {
+	server, requests := newFileServer(t)
+	talk := &parser.Talk{
+		URL:          "https://www.ted.com/talks/test_slug",
+		Slug:         "test_slug",
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4"},
+		SubtitleURLs: map[string]string{"en": server.URL + "/en.srt"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+
+	err := runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle-only")
+	assert.ErrorContains(t, err, "--subtitle-only needs the languages to download with --subtitle")
+
+	err = runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle-only", "--subtitle", "en")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	assert.FileExists(t, filepath.Join(dir, "test_slug", "en.srt"))
+	assert.NoFileExists(t, filepath.Join(dir, "test_slug", "720p.mp4"))
+}
+
+func TestDownload_Quiet(t *testing.T) {
+	server, _ := newFileServer(t)
+	talk := &parser.Talk{
+		URL:       "https://www.ted.com/talks/test_slug",
+		Slug:      "test_slug",
+		VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+
+	// Capture stdout and stderr
+	stdout, stderr := os.Stdout, os.Stderr
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	os.Stdout, os.Stderr = w, w
+	err = runDownloadCmd(t, p, talk.URL, "--output", t.TempDir(), "--quiet")
+	os.Stdout, os.Stderr = stdout, stderr
+	assert.NoError(t, w.Close())
+	assert.NoError(t, err)
+
+	output, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Empty(t, string(output))
+}
+
+func TestDownload_BatchManifest(t *testing.T) {
+	server, requests := newFileServer(t)
+	talk := &parser.Talk{
+		URL:       "https://www.ted.com/talks/test_slug",
+		Slug:      "test_slug",
+		VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+	batch := filepath.Join(dir, "urls.txt")
+	assert.NoError(t, os.WriteFile(batch, []byte(talk.URL+"\nmissing talk\n"), 0644))
+
+	err := runDownloadCmd(t, p, "--batch", batch, "--output", dir)
+	assert.ErrorContains(t, err, "1 of 2 downloads failed")
+	m, err := loadManifest(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, statusDone, m.Entries[talk.URL].Status)
+	assert.Equal(t, statusFailed, m.Entries["missing talk"].Status)
+	assert.Contains(t, m.Entries["missing talk"].Error, "talk not found")
+
+	// A second run skips the finished talk without any request
+	fetched := atomic.LoadInt32(requests)
+	err = runDownloadCmd(t, p, "--batch", batch, "--output", dir)
+	assert.ErrorContains(t, err, "1 of 2 downloads failed")
+	assert.Equal(t, fetched, atomic.LoadInt32(requests))
+
+	// Other options download it again
+	err = runDownloadCmd(t, p, "--batch", batch, "--output", dir, "--quality", "best")
+	assert.ErrorContains(t, err, "1 of 2 downloads failed")
+	assert.Greater(t, atomic.LoadInt32(requests), fetched)
+}
+
+func TestDownload_BatchTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow.mp4" {
+			// Stall until the batch gives up
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	talks := map[string]*parser.Talk{}
+	for _, name := range []string{"fast", "slow", "later"} {
+		talk := &parser.Talk{
+			URL:       "https://www.ted.com/talks/" + name,
+			Slug:      name,
+			VideoURLs: map[string]string{"720p": server.URL + "/" + name + ".mp4"},
+		}
+		talks[talk.URL] = talk
+	}
+	p := &fakeParser{talks: talks}
+	dir := t.TempDir()
+	batch := filepath.Join(dir, "urls.txt")
+	targets := "https://www.ted.com/talks/fast\nhttps://www.ted.com/talks/slow\nhttps://www.ted.com/talks/later\n"
+	assert.NoError(t, os.WriteFile(batch, []byte(targets), 0644))
+
+	err := runDownloadCmd(t, p, "--batch", batch, "--output", dir, "--batch-timeout", "200ms")
+	assert.ErrorContains(t, err, "batch deadline reached: 2 of 3 talks remaining")
+	assert.FileExists(t, filepath.Join(dir, "fast", "720p.mp4"))
+
+	// The unfinished talks are left for the next run
+	m, err := loadManifest(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, statusDone, m.Entries["https://www.ted.com/talks/fast"].Status)
+	assert.Equal(t, statusPending, m.Entries["https://www.ted.com/talks/slow"].Status)
+	assert.Equal(t, statusPending, m.Entries["https://www.ted.com/talks/later"].Status)
+
+	err = runDownloadCmd(t, p, "--batch", batch, "--output", dir, "--batch-timeout", "-1s")
+	assert.ErrorContains(t, err, "invalid --batch-timeout -1s")
+}
+
+func TestDownload_DurationFilter(t *testing.T) {
+	server, _ := newFileServer(t)
+	talks := map[string]*parser.Talk{}
+	for name, duration := range map[string]string{"short": "4:59", "medium": "12:34", "long": "1:02:03", "unknown": ""} {
+		talk := &parser.Talk{
+			URL:       "https://www.ted.com/talks/" + name,
+			Slug:      name,
+			Duration:  duration,
+			VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4"},
+		}
+		talks[talk.URL] = talk
+	}
+	p := &fakeParser{talks: talks}
+	dir := t.TempDir()
+	batch := filepath.Join(dir, "urls.txt")
+	targets := "https://www.ted.com/talks/short\nhttps://www.ted.com/talks/medium\nhttps://www.ted.com/talks/long\nhttps://www.ted.com/talks/unknown\n"
+	assert.NoError(t, os.WriteFile(batch, []byte(targets), 0644))
+
+	err := runDownloadCmd(t, p, "--batch", batch, "--output", dir, "--min-duration", "5m", "--max-duration", "20m")
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "short", "720p.mp4"))
+	assert.FileExists(t, filepath.Join(dir, "medium", "720p.mp4"))
+	assert.NoFileExists(t, filepath.Join(dir, "long", "720p.mp4"))
+	assert.FileExists(t, filepath.Join(dir, "unknown", "720p.mp4"))
+
+	// Other limits download the talks skipped before
+	err = runDownloadCmd(t, p, "--batch", batch, "--output", dir)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "long", "720p.mp4"))
+
+	err = runDownloadCmd(t, p, "talk", "--min-duration", "20m", "--max-duration", "5m")
+	assert.ErrorContains(t, err, "--min-duration 20m0s is longer than --max-duration 5m0s")
+}
+
+func TestDownload_Flat(t *testing.T) {
+	server, _ := newFileServer(t)
+	talk := &parser.Talk{
+		URL:          "https://www.ted.com/talks/test_slug",
+		Slug:         "test_slug",
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4"},
+		SubtitleURLs: map[string]string{"en": server.URL + "/en.srt"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+
+	err := runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle", "en", "--flat", "--metadata")
+	assert.NoError(t, err)
+	for _, name := range []string{"test_slug-720p.mp4", "test_slug-en.srt", "test_slug-metadata.json"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+	assert.NoDirExists(t, filepath.Join(dir, "test_slug"))
+}
+
+func TestDownload_SubtitleIsSRT(t *testing.T) {
+	const srt = "1\n00:00:00,000 --> 00:00:02,500\nHello.\n\n"
+	subtitlesAPI := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql":
+			_, _ = w.Write([]byte(`{"data": {"videos": {"nodes": [{
+				"id": "399",
+				"nativeDownloads": {"medium": "http://` + r.Host + `/talk-720p.mp4"},
+				"subtitledDownloads": [{"low": "http://` + r.Host + `/talk-en.mp4", "internalLanguageCode": "en", "languageName": "English"}]
+			}]}}}`))
+		case "/talks/subtitles/id/399/lang/en":
+			if !subtitlesAPI {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"captions": [{"content": "Hello.", "startTime": 0, "duration": 2500, "startOfParagraph": true}]}`))
+		case "/talks/subtitles/id/399/lang/en.srt":
+			_, _ = w.Write([]byte(srt))
+		case "/talk-en.mp4":
+			_, _ = w.Write([]byte("video with burned-in subtitles"))
+		default:
+			_, _ = w.Write([]byte("<html><h1>Test Title</h1></html>"))
+		}
+	}))
+	defer server.Close()
+
+	p := parser.NewWithClient(server.Client())
+	p.BaseURL = server.URL
+	p.GraphqlURL = server.URL + "/graphql"
+	p.MaxRetries = 1
+
+	// Converted from the subtitles API, or downloaded from the .srt
+	// endpoint when the API fails
+	for _, subtitlesAPI = range []bool{true, false} {
+		dir := t.TempDir()
+		err := runDownloadCmd(t, p, server.URL+"/talks/test_slug", "--output", dir, "--subtitle-only", "--subtitle", "en")
+		assert.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(dir, "test_slug", "en.srt"))
+		assert.NoError(t, err)
+		assert.Equal(t, srt, string(content))
+	}
+}
+
+func TestDownload_SubtitleHTMLOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql":
+			_, _ = w.Write([]byte(`{"errors": [{"message": "Invalid slug"}]}`))
+		case "/talk-720p.mp4":
+			_, _ = w.Write([]byte("video"))
+		default:
+			// The talk page links to a subtitle page, not a subtitle file
+			_, _ = w.Write([]byte(`<html><h1>Test Title</h1>
+				<script>talkPage.init({"playerData": {"talks": [{"player_talks": [{"resources": {"h264": [
+					{"quality": "720p", "file": "http://` + r.Host + `/talk-720p.mp4"}
+				]}}]}]}})</script>
+				<a href="/talks/subtitles/en" data-language="en">English</a></html>`))
+		}
+	}))
+	defer server.Close()
+
+	p := parser.NewWithClient(server.Client())
+	p.BaseURL = server.URL
+	p.GraphqlURL = server.URL + "/graphql"
+	p.MaxRetries = 1
+
+	dir := t.TempDir()
+	err := runDownloadCmd(t, p, server.URL+"/talks/test_slug", "--output", dir, "--subtitle", "en")
+	assert.ErrorContains(t, err, "subtitle language en not available (available: none)")
+	assert.NoFileExists(t, filepath.Join(dir, "test_slug", "en.srt"))
+}
+
+func TestDownload_OverwritePolicy(t *testing.T) {
+	var mu sync.Mutex
+	var gets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			gets = append(gets, r.URL.Path)
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	talk := &parser.Talk{
+		URL:          "https://www.ted.com/talks/test_slug",
+		Slug:         "test_slug",
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4"},
+		SubtitleURLs: map[string]string{"en": server.URL + "/en.srt"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+
+	err := runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle", "en")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/720p.mp4", "/en.srt"}, gets)
+
+	// Only the subtitles are fetched again
+	gets = nil
+	err = runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle", "en", "--force", "--no-overwrite-video", "--overwrite-subtitle")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/en.srt"}, gets)
+
+	err = runDownloadCmd(t, p, talk.URL, "--output", dir, "--overwrite-video", "--no-overwrite-video")
+	assert.ErrorContains(t, err, "--overwrite-video cannot be combined with --no-overwrite-video")
+}
+
+func TestDownload_Cancelled(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Header().Set("Content-Length", "1000")
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	talk := &parser.Talk{
+		URL:       "https://www.ted.com/talks/test_slug",
+		Slug:      "test_slug",
+		VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+
+	// Cancelled like Ctrl+C once the download is under way
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-started
+		cancel()
+	}()
+	err := runDownloadCmdContext(t, ctx, p, talk.URL, "--output", dir)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "download cancelled")
+	assert.NoFileExists(t, filepath.Join(dir, "test_slug", "720p.mp4"))
+}
+
+func TestDownload_DryRun(t *testing.T) {
+	server, requests := newFileServer(t)
+	talk := &parser.Talk{
+		Title:        "Test Title",
+		URL:          "https://www.ted.com/talks/test_slug",
+		Slug:         "test_slug",
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4"},
+		SubtitleURLs: map[string]string{"en": server.URL + "/en.srt"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+	talkDir := filepath.Join(dir, "test_slug")
+
+	output := captureStdout(t, func() {
+		assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle", "en", "--metadata", "--dry-run"))
+	})
+	assert.Equal(t, "Dry run, nothing will be downloaded:\n"+
+		"  video (720p): "+server.URL+"/720p.mp4\n"+
+		"    -> "+filepath.Join(talkDir, "720p.mp4")+"\n"+
+		"  subtitle (en): "+server.URL+"/en.srt\n"+
+		"    -> "+filepath.Join(talkDir, "en.srt")+"\n"+
+		"  metadata\n"+
+		"    -> "+filepath.Join(talkDir, "metadata.json")+"\n", output)
+
+	output = captureStdout(t, func() {
+		assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle", "en", "--dry-run", "--json"))
+	})
+	var result talkResult
+	assert.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.True(t, result.DryRun)
+	assert.Equal(t, &fileResult{Path: filepath.Join(talkDir, "720p.mp4"), URL: server.URL + "/720p.mp4"}, result.Video)
+	assert.Equal(t, map[string]fileResult{"en": {Path: filepath.Join(talkDir, "en.srt"), URL: server.URL + "/en.srt"}}, result.Subtitles)
+
+	// Nothing was fetched or written
+	assert.Zero(t, atomic.LoadInt32(requests))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestTalkPath(t *testing.T) {
+	dir := t.TempDir()
+	d, err := downloader.New(dir)
+	assert.NoError(t, err)
+	video := downloader.NameFields{Slug: "test_slug", Quality: "720p"}
+	sub := downloader.NameFields{Slug: "test_slug", Lang: "zh-cn"}
+	out := filepath.Join(dir, "out")
+
+	tests := []struct {
+		name   string
+		fields downloader.NameFields
+		format string
+		path   string
+		want   string
+	}{
+		{"templated video", video, "720p.mp4", "", filepath.Join(dir, "test_slug", "720p.mp4")},
+		{"templated subtitle", sub, "zh-cn.srt", "", filepath.Join(dir, "test_slug", "zh-cn.srt")},
+		{"video", video, "720p.mp4", filepath.Join(out, "talk.mkv"), filepath.Join(out, "talk.mkv")},
+		{"video without extension", video, "720p.mp4", filepath.Join(out, "talk"), filepath.Join(out, "talk.mp4")},
+		{"audio without extension", downloader.NameFields{Quality: "audio"}, "audio.mp3", filepath.Join(out, "talk"), filepath.Join(out, "talk.mp3")},
+		{"subtitle", sub, "zh-cn.srt", filepath.Join(out, "talk.mp4"), filepath.Join(out, "talk.zh-cn.srt")},
+		{"subtitle without extension", sub, "zh-cn.srt", filepath.Join(out, "talk"), filepath.Join(out, "talk.zh-cn.srt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := talkPath(d, tt.fields, tt.format, tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// findFFmpeg returns the path of the ffmpeg binary, or a clear error if it is missing
+func findFFmpeg() (string, error) {
+	path, err := downloader.FindFFmpeg()
+	if err != nil {
+		return "", fmt.Errorf("%w; it is required for --embed-subtitles", err)
+	}
+	return path, nil
+}
+
+// embedLanguages returns the downloaded subtitle languages selected by the
+// --embed-subtitles value: a comma-separated list, or "all".
+// Languages that were not downloaded are skipped with a warning.
+func embedLanguages(downloaded map[string]fileResult, value string) []string {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, lang := range strings.Split(value, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang == "" || seen[strings.ToLower(lang)] {
+			continue
+		}
+		if lang == "all" {
+			all := make([]string, 0, len(downloaded))
+			for code := range downloaded {
+				all = append(all, code)
+			}
+			sort.Strings(all)
+			return all
+		}
+		seen[strings.ToLower(lang)] = true
+		code, ok := downloadedCode(downloaded, lang)
+		if !ok {
+			warnf("subtitle %s was not downloaded, not embedding it (add it to --subtitle)\n", lang)
+			continue
+		}
+		langs = append(langs, code)
+	}
+	return langs
+}
+
+// embedSubtitles muxes each downloaded video and the subtitles selected by
+// --embed-subtitles into an .mkv, then removes the intermediate files.
+// result is updated to point at the .mkv files.
+func embedSubtitles(ctx context.Context, talk *parser.Talk, result *talkResult) error {
+	langs := embedLanguages(result.Subtitles, embedSubs)
+	if len(langs) == 0 {
+		return fmt.Errorf("no downloaded subtitles to embed; select languages with --subtitle")
+	}
+
+	ffmpeg, err := findFFmpeg()
+	if err != nil {
+		return err
+	}
+
+	// With several qualities every video gets the subtitles
+	videos := result.Videos
+	if len(videos) == 0 {
+		videos = map[string]fileResult{result.Quality: *result.Video}
+	}
+	qualities := make([]string, 0, len(videos))
+	for quality := range videos {
+		qualities = append(qualities, quality)
+	}
+	sort.Strings(qualities)
+
+	infof("Embedding subtitles (%s)...\n", strings.Join(langs, ", "))
+	for _, quality := range qualities {
+		mkv, err := muxSubtitles(ctx, ffmpeg, talk, videos[quality].Path, langs, result.Subtitles)
+		if err != nil {
+			return err
+		}
+		removeFiles(videos[quality].Path)
+		if len(result.Videos) > 0 {
+			result.Videos[quality] = *mkv
+		}
+		if quality == result.Quality {
+			result.Video = mkv
+		}
+	}
+
+	// Remove the subtitles now that they are inside every .mkv
+	for _, lang := range langs {
+		removeFiles(result.Subtitles[lang].Path)
+		delete(result.Subtitles, lang)
+	}
+	return nil
+}
+
+// muxSubtitles muxes the video at videoPath and the subtitles langs into an
+// .mkv next to it
+func muxSubtitles(ctx context.Context, ffmpeg string, talk *parser.Talk, videoPath string, langs []string, subtitles map[string]fileResult) (*fileResult, error) {
+	mkvPath := strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ".mkv"
+
+	args := []string{"-y", "-loglevel", "error", "-i", videoPath}
+	for _, lang := range langs {
+		args = append(args, "-i", subtitles[lang].Path)
+	}
+	args = append(args, "-map", "0")
+	for i := range langs {
+		args = append(args, "-map", strconv.Itoa(i+1))
+	}
+	args = append(args, "-c", "copy", "-c:s", "srt")
+	for i, lang := range langs {
+		title := talk.SubtitleLanguages[lang]
+		if title == "" {
+			title = lang
+		}
+		args = append(args, fmt.Sprintf("-metadata:s:s:%d", i), "language="+lang)
+		args = append(args, fmt.Sprintf("-metadata:s:s:%d", i), "title="+title)
+	}
+	args = append(args, mkvPath)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	mkv := &fileResult{Path: mkvPath}
+	if info, err := os.Stat(mkvPath); err == nil {
+		mkv.Size = info.Size()
+	}
+	return mkv, nil
+}
+
+// removeFiles removes an intermediate file and its checksum file
+func removeFiles(path string) {
+	for _, file := range []string{path, path + ".sha256"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			warnf("failed to remove %s: %v\n", file, err)
+		}
+	}
+}
+
+// downloadedCode returns the code under which subtitle lang was downloaded,
+// matched case-insensitively
+func downloadedCode(downloaded map[string]fileResult, lang string) (string, bool) {
+	for code := range downloaded {
+		if strings.EqualFold(code, lang) {
+			return code, true
+		}
+	}
+	return "", false
+}
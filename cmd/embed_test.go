@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFFmpeg puts an ffmpeg script on PATH that writes the content of its
+// inputs to its output, the last argument, and returns the file its
+// arguments are logged to, one per line
+func fakeFFmpeg(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args.log")
+	script := `#!/bin/sh
+for arg; do echo "$arg"; done > "` + log + `"
+inputs=""
+while [ $# -gt 1 ]; do
+	if [ "$1" = "-i" ]; then inputs="$inputs $2"; shift; fi
+	shift
+done
+cat $inputs > "$1"
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestDownload_EmbedSubtitles(t *testing.T) {
+	log := fakeFFmpeg(t)
+	server, _ := newFileServer(t)
+	talk := &parser.Talk{
+		URL:               "https://www.ted.com/talks/test_slug",
+		Slug:              "test_slug",
+		VideoURLs:         map[string]string{"720p": server.URL + "/720p.mp4"},
+		SubtitleURLs:      map[string]string{"en": server.URL + "/en.srt", "fr": server.URL + "/fr.srt"},
+		SubtitleLanguages: map[string]string{"en": "English"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+
+	err := runDownloadCmd(t, p, talk.URL, "--output", dir, "--subtitle", "en,fr", "--embed-subtitles", "EN,fr,de")
+	assert.NoError(t, err)
+
+	talkDir := filepath.Join(dir, "test_slug")
+	content, err := os.ReadFile(filepath.Join(talkDir, "720p.mkv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "contentcontentcontent", string(content))
+	// The intermediates are inside the .mkv
+	for _, name := range []string{"720p.mp4", "en.srt", "fr.srt"} {
+		assert.NoFileExists(t, filepath.Join(talkDir, name))
+	}
+
+	args, err := os.ReadFile(log)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"-y", "-loglevel", "error",
+		"-i", filepath.Join(talkDir, "720p.mp4"),
+		"-i", filepath.Join(talkDir, "en.srt"),
+		"-i", filepath.Join(talkDir, "fr.srt"),
+		"-map", "0", "-map", "1", "-map", "2",
+		"-c", "copy", "-c:s", "srt",
+		"-metadata:s:s:0", "language=en", "-metadata:s:s:0", "title=English",
+		"-metadata:s:s:1", "language=fr", "-metadata:s:s:1", "title=fr",
+		filepath.Join(talkDir, "720p.mkv"),
+	}, strings.Split(strings.TrimSpace(string(args)), "\n"))
+
+	// With several qualities every video gets the subtitles
+	dir = t.TempDir()
+	talkDir = filepath.Join(dir, "test_slug")
+	talk.VideoURLs["1080p"] = server.URL + "/1080p.mp4"
+	err = runDownloadCmd(t, p, talk.URL, "--output", dir, "--quality", "720p,1080p", "--subtitle", "en", "--embed-subtitles")
+	assert.NoError(t, err)
+	entries, err := os.ReadDir(talkDir)
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"1080p.mkv", "720p.mkv"}, names)
+
+	// Without downloaded subtitles there is nothing to embed
+	err = runDownloadCmd(t, p, talk.URL, "--output", t.TempDir(), "--embed-subtitles")
+	assert.ErrorContains(t, err, "no downloaded subtitles to embed")
+}
+
+func TestDownload_AudioOnly(t *testing.T) {
+	fakeFFmpeg(t)
+	server, _ := newFileServer(t)
+	talk := &parser.Talk{
+		URL:       "https://www.ted.com/talks/test_slug",
+		Slug:      "test_slug",
+		VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4", "360p": server.URL + "/360p.mp4"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+	dir := t.TempDir()
+	audioPath := filepath.Join(dir, "test_slug", "audio.mp3")
+
+	// TED offers no audio file, so ffmpeg extracts it from the smallest video
+	output := captureStdout(t, func() {
+		assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", dir, "--audio-only"))
+	})
+	assert.Contains(t, output, "Audio source: extracted with ffmpeg from the smallest video, TED offers no audio file for this talk\n")
+	content, err := os.ReadFile(audioPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "test_slug", "audio.source.mp4"))
+
+	output = captureStdout(t, func() {
+		assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", t.TempDir(), "--audio-only", "--json"))
+	})
+	var result talkResult
+	assert.NoError(t, json.Unmarshal([]byte(output), &result))
+	assert.Equal(t, "video", result.AudioSource)
+
+	talk.AudioURL = server.URL + "/audio.mp3"
+	output = captureStdout(t, func() {
+		assert.NoError(t, runDownloadCmd(t, p, talk.URL, "--output", t.TempDir(), "--audio-only"))
+	})
+	assert.Contains(t, output, "Audio source: TED audio file\n")
+}
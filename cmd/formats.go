@@ -0,0 +1,322 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// printFormats prints the video qualities and subtitle languages available for a talk
+func printFormats(talk *parser.Talk) {
+	if talk.Title != "" {
+		fmt.Printf("%s\n\n", strings.TrimSpace(talk.Title))
+	}
+	if talk.Description != "" {
+		fmt.Printf("%s\n\n", truncateText(talk.Description, maxDescriptionWidth))
+	}
+	printVideoTable(talk)
+	fmt.Println()
+	printSubtitleTable(talk)
+}
+
+// newFormatsResult returns what printFormats shows, for --json
+func newFormatsResult(talk *parser.Talk) *formatsResult {
+	sizes := make(map[string]int64)
+	for _, format := range talk.VideoFormats {
+		sizes[format.Quality] = format.Size
+	}
+	result := &formatsResult{
+		Videos:          []videoFormat{},
+		Subtitles:       []parser.Language{},
+		SubtitledVideos: sortedKeys(talk.SubtitledVideoURLs),
+	}
+	for _, quality := range sortedQualities(talk.VideoURLs) {
+		result.Videos = append(result.Videos, videoFormat{Quality: quality, Size: sizes[quality], URL: talk.VideoURLs[quality]})
+	}
+	for _, lang := range sortedKeys(talk.SubtitleURLs) {
+		result.Subtitles = append(result.Subtitles, parser.Language{Code: lang, Name: talk.SubtitleLanguages[lang]})
+	}
+	return result
+}
+
+// printVideoTable prints the video qualities of a talk with their sizes
+func printVideoTable(talk *parser.Talk) {
+	sizes := make(map[string]int64)
+	for _, format := range talk.VideoFormats {
+		sizes[format.Quality] = format.Size
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUALITY\tSIZE")
+	for _, quality := range sortedQualities(talk.VideoURLs) {
+		size := "unknown"
+		if n, ok := sizes[quality]; ok && n > 0 {
+			size = formatBytes(n)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", quality, size)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Println("flush output error:", err)
+	}
+}
+
+// printSubtitleTable prints the subtitle languages of a talk, followed by
+// the languages of its videos with burned-in subtitles
+func printSubtitleTable(talk *parser.Talk) {
+	if len(talk.SubtitleURLs) == 0 {
+		fmt.Println("No subtitles available.")
+		printSubtitledVideos(talk)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SUBTITLE\tLANGUAGE")
+	for _, lang := range sortedKeys(talk.SubtitleURLs) {
+		fmt.Fprintf(w, "%s\t%s\n", lang, talk.SubtitleLanguages[lang])
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Println("flush output error:", err)
+	}
+	printSubtitledVideos(talk)
+}
+
+// printSubtitledVideos lists the languages TED offers videos with burned-in
+// subtitles for, which --subtitle doesn't download
+func printSubtitledVideos(talk *parser.Talk) {
+	if len(talk.SubtitledVideoURLs) == 0 {
+		return
+	}
+	fmt.Printf("\nVideos with burned-in subtitles: %s\n", strings.Join(sortedKeys(talk.SubtitledVideoURLs), ", "))
+}
+
+// maxDescriptionWidth is how many characters of a description printFormats shows
+const maxDescriptionWidth = 200
+
+// truncateText shortens s to at most max characters, cutting at a word
+// boundary when possible and marking the cut with an ellipsis
+func truncateText(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	cut := string(runes[:max-1])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// selection is what a download fetches from a talk, resolved from the flags
+type selection struct {
+	// videos holds one video per available --quality, in the order asked
+	// for; empty with --audio-only or --subtitle-only
+	videos []video
+	langs  []string // subtitle codes as the talk spells them
+	// audioSource and audioURL tell where --audio-only gets the audio from,
+	// see downloader.SelectAudio
+	audioSource downloader.AudioSource
+	audioURL    string
+}
+
+// video is a video quality of a talk with its URL
+type video struct {
+	quality string
+	url     string
+}
+
+// resolveSelection checks --quality (or --audio-only) and --subtitle against
+// the talk, reporting every unavailable choice at once with what the talk
+// offers instead
+func resolveSelection(talk *parser.Talk) (*selection, error) {
+	sel := &selection{}
+	var errs []error
+	switch {
+	case subOnly:
+	case audioOnly:
+		source, url, err := downloader.SelectAudio(talk)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		sel.audioSource, sel.audioURL = source, url
+	default:
+		// With several qualities the unavailable ones are skipped
+		requested := splitQualities(quality)
+		for _, value := range requested {
+			q, videoURL, err := selectQuality(talk, value)
+			switch {
+			case err != nil && len(requested) > 1:
+				warnf("%v, skipping it\n", err)
+			case err != nil:
+				errs = append(errs, err)
+			case !slices.ContainsFunc(sel.videos, func(v video) bool { return v.quality == q }):
+				sel.videos = append(sel.videos, video{q, videoURL})
+			}
+		}
+		if len(requested) > 1 && len(sel.videos) == 0 {
+			errs = append(errs, fmt.Errorf("none of the video qualities %s is available", strings.Join(requested, ", ")))
+		}
+	}
+
+	var missing []string
+	for _, requested := range subtitleLanguages(talk, subtitle) {
+		if lang, ok := talk.SubtitleCode(requested); ok {
+			sel.langs = append(sel.langs, lang)
+		} else {
+			missing = append(missing, requested)
+		}
+	}
+	if len(missing) > 0 {
+		available := "none"
+		if len(talk.SubtitleURLs) > 0 {
+			available = strings.Join(sortedKeys(talk.SubtitleURLs), ", ")
+		}
+		errs = append(errs, fmt.Errorf("subtitle language %s not available (available: %s)", strings.Join(missing, ", "), available))
+	}
+
+	if subOnly && len(missing) == 0 && len(sel.langs) == 0 {
+		errs = append(errs, fmt.Errorf("no subtitles available for this talk"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitQualities splits a comma-separated --quality value like "720p,1080p"
+func splitQualities(value string) []string {
+	var qualities []string
+	for _, q := range strings.Split(value, ",") {
+		if q = strings.TrimSpace(q); q != "" {
+			qualities = append(qualities, q)
+		}
+	}
+	return qualities
+}
+
+// selectQuality resolves a single --quality value against the videos of a talk and
+// returns the chosen quality with its URL. "best" and "worst" pick the highest
+// and lowest resolution; an unavailable quality is reported with the closest one.
+func selectQuality(talk *parser.Talk, requested string) (string, string, error) {
+	urls := videoURLs(talk)
+	if len(urls) == 0 {
+		return "", "", fmt.Errorf("no video available for this talk")
+	}
+
+	qualities := sortedQualities(urls)
+	switch strings.ToLower(requested) {
+	case "best":
+		return qualities[0], urls[qualities[0]], nil
+	case "worst":
+		worst := qualities[len(qualities)-1]
+		return worst, urls[worst], nil
+	}
+	requested = parser.NormalizeQuality(requested)
+	if url, ok := urls[requested]; ok {
+		return requested, url, nil
+	}
+
+	available := strings.Join(qualities, ", ")
+	if closest := closestQuality(qualities, requested); closest != "" {
+		return "", "", fmt.Errorf("video quality %s not available, the closest is %s (available: %s)", requested, closest, available)
+	}
+	return "", "", fmt.Errorf("video quality %s not available (available: %s)", requested, available)
+}
+
+// videoURLs returns the URL of every video quality of a talk, preferring
+// VideoURLs over VideoFormats
+func videoURLs(talk *parser.Talk) map[string]string {
+	urls := make(map[string]string, len(talk.VideoURLs))
+	for _, format := range talk.VideoFormats {
+		if format.URL != "" {
+			urls[format.Quality] = format.URL
+		}
+	}
+	for quality, url := range talk.VideoURLs {
+		if url != "" {
+			urls[quality] = url
+		}
+	}
+	return urls
+}
+
+// closestQuality returns the quality nearest in resolution to requested,
+// preferring the higher one on a tie, or "" if requested isn't a resolution
+func closestQuality(qualities []string, requested string) string {
+	target := parser.QualityHeight(requested)
+	if target == 0 {
+		return ""
+	}
+	closest, best := "", -1
+	for _, quality := range qualities { // highest first, so ties keep the higher one
+		height := parser.QualityHeight(quality)
+		if height == 0 {
+			continue
+		}
+		diff := height - target
+		if diff < 0 {
+			diff = -diff
+		}
+		if best < 0 || diff < best {
+			closest, best = quality, diff
+		}
+	}
+	return closest
+}
+
+// sortedQualities returns the keys of videoURLs from highest to lowest resolution
+func sortedQualities(videoURLs map[string]string) []string {
+	qualities := make([]string, 0, len(videoURLs))
+	for quality := range videoURLs {
+		qualities = append(qualities, quality)
+	}
+	sort.Slice(qualities, func(i, j int) bool {
+		hi, hj := parser.QualityHeight(qualities[i]), parser.QualityHeight(qualities[j])
+		if hi != hj {
+			return hi > hj
+		}
+		return qualities[i] < qualities[j]
+	})
+	return qualities
+}
+
+// formatBytes renders a byte count in human-friendly binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// languageLabel returns the display name of a subtitle language with its code,
+// e.g. "Chinese, Simplified (zh-cn)", or just the code when no name is known
+func languageLabel(talk *parser.Talk, code string) string {
+	if name := talk.SubtitleLanguages[code]; name != "" {
+		return fmt.Sprintf("%s (%s)", name, code)
+	}
+	return code
+}
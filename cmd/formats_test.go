@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	assert.NoError(t, w.Close())
+	output, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(output)
+}
+
+func TestPrintFormats(t *testing.T) {
+	talk := &parser.Talk{
+		Title:       " Test Title ",
+		Description: "A talk about testing.",
+		VideoURLs: map[string]string{
+			"360p":  "https://example.com/360p.mp4",
+			"1080p": "https://example.com/1080p.mp4",
+		},
+		VideoFormats:       []parser.VideoFormat{{Quality: "1080p", Size: 3 << 20}},
+		SubtitleURLs:       map[string]string{"fr": "https://example.com/fr.srt", "en": "https://example.com/en.srt"},
+		SubtitleLanguages:  map[string]string{"en": "English", "fr": "French"},
+		SubtitledVideoURLs: map[string]string{"es": "https://example.com/es.mp4"},
+	}
+	output := captureStdout(t, func() { printFormats(talk) })
+	assert.Equal(t, `Test Title
+
+A talk about testing.
+
+QUALITY  SIZE
+1080p    3.0 MiB
+360p     unknown
+
+SUBTITLE  LANGUAGE
+en        English
+fr        French
+
+Videos with burned-in subtitles: es
+`, output)
+
+	output = captureStdout(t, func() { printFormats(&parser.Talk{}) })
+	assert.Contains(t, output, "No subtitles available.")
+}
+
+func TestSortedQualities(t *testing.T) {
+	qualities := sortedQualities(map[string]string{
+		"360p": "", "1080p": "", "720p": "", "audio": "", "240p": "",
+	})
+	// Highest resolution first, unknown qualities last
+	assert.Equal(t, []string{"1080p", "720p", "360p", "240p", "audio"}, qualities)
+}
+
+func TestSelectQuality(t *testing.T) {
+	talk := &parser.Talk{
+		VideoURLs: map[string]string{
+			"360p":  "https://example.com/360p.mp4",
+			"1080p": "https://example.com/1080p.mp4",
+		},
+		VideoFormats: []parser.VideoFormat{{Quality: "480p", URL: "https://example.com/480p.mp4"}},
+	}
+	tests := []struct {
+		requested string
+		quality   string
+		err       string
+	}{
+		{requested: "best", quality: "1080p"},
+		{requested: "WORST", quality: "360p"},
+		{requested: "480p", quality: "480p"},
+		{requested: "medium", err: "video quality 720p not available, the closest is 480p (available: 1080p, 480p, 360p)"},
+		{requested: "2160p", err: "video quality 2160p not available, the closest is 1080p (available: 1080p, 480p, 360p)"},
+		{requested: "audio", err: "video quality audio not available (available: 1080p, 480p, 360p)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.requested, func(t *testing.T) {
+			quality, url, err := selectQuality(talk, tt.requested)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.quality, quality)
+			assert.Equal(t, "https://example.com/"+tt.quality+".mp4", url)
+		})
+	}
+
+	_, _, err := selectQuality(&parser.Talk{}, "best")
+	assert.EqualError(t, err, "no video available for this talk")
+}
+
+func TestResolveSelection(t *testing.T) {
+	talk := &parser.Talk{
+		VideoURLs: map[string]string{
+			"720p":  "https://example.com/720p.mp4",
+			"1080p": "https://example.com/1080p.mp4",
+		},
+		AudioURL:     "https://example.com/audio.mp3",
+		SubtitleURLs: map[string]string{"en": "https://example.com/en.srt", "zh-cn": "https://example.com/zh-cn.srt"},
+	}
+	savedQuality, savedSubtitle := quality, subtitle
+	savedAudio, savedSub := audioOnly, subOnly
+	t.Cleanup(func() {
+		quality, subtitle = savedQuality, savedSubtitle
+		audioOnly, subOnly = savedAudio, savedSub
+	})
+
+	tests := []struct {
+		name      string
+		quality   string
+		subtitle  string
+		audioOnly bool
+		subOnly   bool
+		want      *selection
+		err       string
+	}{
+		{
+			name: "video and subtitles", quality: "720p", subtitle: "en,zh-CN",
+			want: &selection{videos: []video{{"720p", "https://example.com/720p.mp4"}}, langs: []string{"en", "zh-cn"}},
+		},
+		{
+			name: "several qualities", quality: "1080p,480p,best",
+			want: &selection{videos: []video{{"1080p", "https://example.com/1080p.mp4"}}},
+		},
+		{
+			name: "audio only", audioOnly: true,
+			want: &selection{audioSource: downloader.AudioFromTED, audioURL: "https://example.com/audio.mp3"},
+		},
+		{name: "subtitles only", subOnly: true, subtitle: "en", want: &selection{langs: []string{"en"}}},
+		{
+			name: "everything missing", quality: "480p", subtitle: "fr,en,de",
+			err: "video quality 480p not available, the closest is 720p (available: 1080p, 720p)\n" +
+				"subtitle language fr, de not available (available: en, zh-cn)",
+		},
+		{name: "subtitles only without any", subOnly: true, err: "no subtitles available for this talk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quality, subtitle = tt.quality, tt.subtitle
+			audioOnly, subOnly = tt.audioOnly, tt.subOnly
+			sel, err := resolveSelection(talk)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, sel)
+		})
+	}
+}
+
+func TestClosestQuality(t *testing.T) {
+	qualities := []string{"1080p", "720p", "360p", "audio"}
+	assert.Equal(t, "360p", closestQuality(qualities, "480p"))
+	assert.Equal(t, "360p", closestQuality(qualities, "240p"))
+	assert.Equal(t, "1080p", closestQuality(qualities, "2160p"))
+	// Ties keep the higher quality
+	assert.Equal(t, "720p", closestQuality(qualities, "540p"))
+	assert.Equal(t, "", closestQuality(qualities, "audio"))
+	assert.Equal(t, "", closestQuality([]string{"audio"}, "720p"))
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatBytes(tt.n), tt.n)
+	}
+}
+
+func TestLanguageLabel(t *testing.T) {
+	talk := &parser.Talk{SubtitleLanguages: map[string]string{"zh-cn": "Chinese, Simplified", "fr": ""}}
+	assert.Equal(t, "Chinese, Simplified (zh-cn)", languageLabel(talk, "zh-cn"))
+	assert.Equal(t, "fr", languageLabel(talk, "fr"))
+	assert.Equal(t, "de", languageLabel(talk, "de"))
+}
+
+func TestDownload_ListFormatsJSON(t *testing.T) {
+	server, requests := newFileServer(t)
+	talk := &parser.Talk{
+		Title:             "Test Title",
+		URL:               "https://www.ted.com/talks/test_slug",
+		VideoURLs:         map[string]string{"720p": server.URL + "/720p.mp4"},
+		VideoFormats:      []parser.VideoFormat{{Quality: "720p", Size: 2048}},
+		SubtitleURLs:      map[string]string{"en": server.URL + "/en.srt"},
+		SubtitleLanguages: map[string]string{"en": "English"},
+	}
+	p := &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}
+
+	var err error
+	output := captureStdout(t, func() {
+		err = runDownloadCmd(t, p, talk.URL, "--output", t.TempDir(), "--list-formats", "--json")
+	})
+	assert.NoError(t, err)
+	var result talkResult
+	assert.NoError(t, json.Unmarshal([]byte(output), &result), output)
+	assert.Equal(t, &formatsResult{
+		Videos:    []videoFormat{{Quality: "720p", Size: 2048, URL: server.URL + "/720p.mp4"}},
+		Subtitles: []parser.Language{{Code: "en", Name: "English"}},
+	}, result.Formats)
+	assert.Zero(t, *requests)
+}
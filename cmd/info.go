@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/baiyutang/tedfetch/internal/downloader"
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// infoCmd represents the info command
+	infoCmd = &cobra.Command{
+		Use:   "info <url or title>",
+		Short: "Print everything known about a talk without downloading it",
+		Long: `Print a talk's metadata, video qualities with their sizes and subtitle languages. For example:
+tedfetch info https://www.ted.com/talks/brene_brown_the_power_of_vulnerability
+tedfetch info "The power of vulnerability" --json`,
+		Args: cobra.ExactArgs(1),
+		RunE: runInfo,
+	}
+
+	// Flags
+	infoJSON bool
+)
+
+// newInfoParser returns the parser used by the info command. Tests replace
+// it to avoid the network.
+var newInfoParser = func() (parser.TalkParser, error) {
+	return newParser()
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+
+	// Add flags
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, "Print the talk as JSON")
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	p, err := newInfoParser()
+	if err != nil {
+		return err
+	}
+
+	var talk *parser.Talk
+	if strings.HasPrefix(args[0], "http") {
+		talk, err = p.ParseURLContext(cmd.Context(), args[0])
+	} else {
+		talk, err = p.ParseTalkDetailsContext(cmd.Context(), args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse talk details: %w", err)
+	}
+	// The downloader only sends HEAD requests for the video sizes, nothing
+	// is written to its directory
+	d, err := downloader.NewWithClient(".", httpClient)
+	if err != nil {
+		return fmt.Errorf("failed to create downloader: %w", err)
+	}
+	d.SetUserAgent(userAgent)
+	d.SetLogger(logger)
+	d.FillVideoSizesContext(cmd.Context(), talk)
+
+	if infoJSON {
+		printJSON(talk)
+		return nil
+	}
+	printInfo(talk)
+	return nil
+}
+
+// printInfo prints the metadata of a talk as a table, followed by its
+// description, video qualities and subtitle languages
+func printInfo(talk *parser.Talk) {
+	url := talk.CanonicalURL
+	if url == "" {
+		url = talk.URL
+	}
+	audio := "no"
+	if talk.AudioURL != "" {
+		audio = "yes"
+	}
+
+	rows := [][2]string{{"Title", strings.TrimSpace(talk.Title)}}
+	for _, speaker := range talk.Speakers {
+		name := speaker.Name
+		if speaker.Title != "" {
+			name = fmt.Sprintf("%s (%s)", name, speaker.Title)
+		}
+		rows = append(rows, [2]string{"Speaker", name})
+	}
+	if len(talk.Speakers) == 0 {
+		rows = append(rows, [2]string{"Speaker", strings.TrimSpace(talk.Speaker)})
+	}
+	rows = append(rows, [][2]string{
+		{"Duration", talk.Duration},
+		{"Published", talk.PublishedDate},
+		{"Views", talk.Views},
+		{"Tags", strings.Join(talk.Tags, ", ")},
+		{"URL", url},
+		{"Audio", audio},
+	}...)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		value := row[1]
+		if value == "" {
+			value = "unknown"
+		}
+		fmt.Fprintf(w, "%s:\t%s\n", row[0], value)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Println("flush output error:", err)
+	}
+
+	if talk.Description != "" {
+		fmt.Printf("\n%s\n", talk.Description)
+	}
+	fmt.Println()
+	printVideoTable(talk)
+	fmt.Println()
+	printSubtitleTable(talk)
+}
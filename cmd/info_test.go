@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfo(t *testing.T) {
+	talk := &parser.Talk{
+		Title:             "Test Title",
+		Speaker:           "Test Speaker",
+		Speakers:          []parser.Speaker{{Name: "Test Speaker", Title: "Researcher"}},
+		URL:               "https://www.ted.com/talks/test_slug",
+		Slug:              "test_slug",
+		Description:       "A talk about testing.",
+		Duration:          "12:34",
+		Tags:              []string{"science", "technology"},
+		PublishedDate:     "2010-12-23",
+		VideoURLs:         map[string]string{"720p": "https://example.com/720p.mp4"},
+		VideoFormats:      []parser.VideoFormat{{Quality: "720p", URL: "https://example.com/720p.mp4", Size: 2 << 20}},
+		SubtitleURLs:      map[string]string{"en": "https://example.com/en.srt"},
+		SubtitleLanguages: map[string]string{"en": "English"},
+	}
+	saved := newInfoParser
+	newInfoParser = func() (parser.TalkParser, error) {
+		return &fakeParser{talks: map[string]*parser.Talk{talk.URL: talk}}, nil
+	}
+	defer func() { newInfoParser = saved }()
+
+	// Capture stdout
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	os.Stdout = w
+	rootCmd.SetArgs([]string{"info", talk.URL})
+	infoCmd.SetContext(context.Background())
+	err = rootCmd.ExecuteContext(context.Background())
+	os.Stdout = stdout
+	assert.NoError(t, w.Close())
+	assert.NoError(t, err)
+
+	output, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	for _, want := range []string{
+		"Title:      Test Title",
+		"Speaker:    Test Speaker (Researcher)",
+		"Duration:   12:34",
+		"Published:  2010-12-23",
+		"Views:      unknown",
+		"Tags:       science, technology",
+		"A talk about testing.",
+		"720p     2.0 MiB",
+		"en        English",
+	} {
+		assert.Contains(t, string(output), want)
+	}
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifestFilename is the batch state file written to the output directory
+const manifestFilename = ".tedfetch-manifest.json"
+
+// Statuses of a manifest entry
+const (
+	statusPending = "pending"
+	statusDone    = "done"
+	statusFailed  = "failed"
+)
+
+// manifest records the progress of --batch downloads, so that a batch that
+// was interrupted skips the talks it already finished when run again
+type manifest struct {
+	path    string
+	Entries map[string]*manifestEntry `json:"entries"` // keyed by batch target
+}
+
+// manifestEntry is the state of one batch target
+type manifestEntry struct {
+	Status    string    `json:"status"`
+	Options   string    `json:"options"` // what was requested, see downloadOptions
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// loadManifest reads the manifest in dir, or returns an empty one if there is none
+func loadManifest(dir string) (*manifest, error) {
+	m := &manifest{
+		path:    filepath.Join(dir, manifestFilename),
+		Entries: make(map[string]*manifestEntry),
+	}
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", m.path, err)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]*manifestEntry)
+	}
+	return m, nil
+}
+
+// done reports whether target was already downloaded with the same options
+func (m *manifest) done(target string) bool {
+	entry, ok := m.Entries[target]
+	return ok && entry.Status == statusDone && entry.Options == downloadOptions()
+}
+
+// set records the status of target, with the error of a failure, and saves
+// the manifest
+func (m *manifest) set(target, status string, err error) error {
+	entry := &manifestEntry{Status: status, Options: downloadOptions(), UpdatedAt: time.Now().UTC()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	m.Entries[target] = entry
+	return m.save()
+}
+
+// save writes the manifest, replacing the previous one atomically so that
+// a crash never leaves a truncated file
+func (m *manifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// downloadOptions describes the flags that decide which files a talk
+// download produces, e.g. "quality=720p subtitle=en,fr"
+func downloadOptions() string {
+	var options string
+	switch {
+	case subOnly:
+		options = fmt.Sprintf("subtitle-only subtitle=%s", subtitle)
+	case audioOnly:
+		options = fmt.Sprintf("audio-only subtitle=%s", subtitle)
+	default:
+		options = fmt.Sprintf("quality=%s subtitle=%s", quality, subtitle)
+	}
+	// Talks skipped for their length are downloaded by other limits
+	if minDuration > 0 {
+		options += fmt.Sprintf(" min-duration=%s", minDuration)
+	}
+	if maxDuration > 0 {
+		options += fmt.Sprintf(" max-duration=%s", maxDuration)
+	}
+	return options
+}
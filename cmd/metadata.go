@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// metadataFilename is the sidecar written by --metadata in each talk directory
+const metadataFilename = "metadata.json"
+
+// writeMetadata saves the talk's metadata as JSON to path
+func writeMetadata(path string, talk *parser.Talk) error {
+	data, err := json.MarshalIndent(talk, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
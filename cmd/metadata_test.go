@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetadata(t *testing.T) {
+	talk := &parser.Talk{
+		Title:     "Test Title",
+		Speaker:   "Test Speaker",
+		URL:       "https://www.ted.com/talks/test_slug",
+		Duration:  "12:34",
+		Tags:      []string{"science"},
+		VideoURLs: map[string]string{"720p": "https://example.com/720p.mp4"},
+	}
+	// The talk directory is created
+	path := filepath.Join(t.TempDir(), "test_slug", metadataFilename)
+	assert.NoError(t, writeMetadata(path, talk))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, byte('\n'), data[len(data)-1])
+	var got parser.Talk
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, *talk, got)
+}
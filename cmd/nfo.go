@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// nfoMovie is the Kodi/Jellyfin movie .nfo written by --nfo
+type nfoMovie struct {
+	XMLName   xml.Name   `xml:"movie"`
+	Title     string     `xml:"title"`
+	Plot      string     `xml:"plot,omitempty"`
+	Premiered string     `xml:"premiered,omitempty"`
+	Year      string     `xml:"year,omitempty"`
+	Runtime   int        `xml:"runtime,omitempty"` // minutes
+	Studio    string     `xml:"studio"`
+	Tags      []string   `xml:"tag"`
+	Actors    []nfoActor `xml:"actor"`
+}
+
+// nfoActor is a speaker of the talk in an .nfo
+type nfoActor struct {
+	Name string `xml:"name"`
+	Role string `xml:"role,omitempty"`
+}
+
+// nfoPath returns the .nfo path next to the media file at mediaPath
+func nfoPath(mediaPath string) string {
+	return strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)) + ".nfo"
+}
+
+// writeNFO saves the talk's metadata as a Kodi-compatible .nfo to path
+func writeNFO(path string, talk *parser.Talk) error {
+	movie := nfoMovie{
+		Title:     strings.TrimSpace(talk.Title),
+		Plot:      talk.Description,
+		Premiered: talk.PublishedDate,
+		Studio:    "TED",
+		Tags:      talk.Tags,
+	}
+	if len(talk.PublishedDate) >= 4 {
+		movie.Year = talk.PublishedDate[:4]
+	}
+	if length, ok := talk.Length(); ok {
+		// Whole minutes, rounded up
+		movie.Runtime = int((length + time.Minute - 1) / time.Minute)
+	}
+	for _, speaker := range talk.Speakers {
+		movie.Actors = append(movie.Actors, nfoActor{Name: speaker.Name, Role: speaker.Title})
+	}
+	if len(movie.Actors) == 0 && talk.Speaker != "" {
+		movie.Actors = append(movie.Actors, nfoActor{Name: strings.TrimSpace(talk.Speaker)})
+	}
+
+	data, err := xml.MarshalIndent(movie, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode nfo: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write nfo: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteNFO(t *testing.T) {
+	talk := &parser.Talk{
+		Title:         ` Cats & dogs: "<why>" we're friends `,
+		Description:   "Less < more & more > less",
+		Duration:      "12:01",
+		PublishedDate: "2010-06-01",
+		Tags:          []string{"science", "r&d"},
+		Speakers:      []parser.Speaker{{Name: `Jane "JJ" O'Neil & co`, Title: "<Biologist>"}},
+	}
+	path := filepath.Join(t.TempDir(), "test_slug", "720p.nfo")
+	assert.NoError(t, writeNFO(path, talk))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), xml.Header))
+
+	// Special characters are escaped, so the file reads back unchanged
+	var got nfoMovie
+	assert.NoError(t, xml.Unmarshal(data, &got))
+	assert.Equal(t, nfoMovie{
+		XMLName:   xml.Name{Local: "movie"},
+		Title:     `Cats & dogs: "<why>" we're friends`,
+		Plot:      "Less < more & more > less",
+		Premiered: "2010-06-01",
+		Year:      "2010",
+		Runtime:   13,
+		Studio:    "TED",
+		Tags:      []string{"science", "r&d"},
+		Actors:    []nfoActor{{Name: `Jane "JJ" O'Neil & co`, Role: "<Biologist>"}},
+	}, got)
+
+	// Without speaker details the display name is the only actor
+	talk.Speakers = nil
+	talk.Speaker = "Tom & Jerry "
+	assert.NoError(t, writeNFO(path, talk))
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	got = nfoMovie{}
+	assert.NoError(t, xml.Unmarshal(data, &got))
+	assert.Equal(t, []nfoActor{{Name: "Tom & Jerry"}}, got.Actors)
+}
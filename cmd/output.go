@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// fileResult describes one downloaded file in --json output
+type fileResult struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	URL    string `json:"url,omitempty"` // with --dry-run, where the file would come from
+}
+
+// talkResult is the --json output for one downloaded talk
+type talkResult struct {
+	Title       string                `json:"title"`
+	Speaker     string                `json:"speaker"`
+	URL         string                `json:"url"`
+	Quality     string                `json:"quality,omitempty"`
+	Video       *fileResult           `json:"video,omitempty"`
+	Videos      map[string]fileResult `json:"videos,omitempty"` // keyed by quality, with several --quality values
+	Audio       *fileResult           `json:"audio,omitempty"`
+	AudioSource string                `json:"audio_source,omitempty"` // "ted", or "video" when extracted with ffmpeg
+	Subtitles   map[string]fileResult `json:"subtitles,omitempty"`    // keyed by language code
+	Metadata    string                `json:"metadata,omitempty"`     // path of metadata.json
+	NFO         string                `json:"nfo,omitempty"`          // path of the .nfo with --nfo
+	NFOs        map[string]string     `json:"nfos,omitempty"`         // keyed by quality, with several --quality values
+	Related     []*talkResult         `json:"related,omitempty"`      // with --with-related
+	Formats     *formatsResult        `json:"formats,omitempty"`      // with --list-formats, instead of downloading
+	DryRun      bool                  `json:"dry_run,omitempty"`      // nothing was downloaded
+}
+
+// formatsResult is the --json output of --list-formats for one talk
+type formatsResult struct {
+	Videos          []videoFormat     `json:"videos"`                     // highest quality first
+	Subtitles       []parser.Language `json:"subtitles"`                  // by code
+	SubtitledVideos []string          `json:"subtitled_videos,omitempty"` // languages of videos with burned-in subtitles
+}
+
+// videoFormat describes one video quality in --list-formats --json output
+type videoFormat struct {
+	Quality string `json:"quality"`
+	Size    int64  `json:"size,omitempty"` // 0 when unknown
+	URL     string `json:"url"`
+}
+
+// batchResult is the --json output for a batch or playlist download
+type batchResult struct {
+	Playlist  string         `json:"playlist,omitempty"`
+	Speaker   string         `json:"speaker,omitempty"`
+	Talks     []*talkResult  `json:"talks"`
+	Failed    []batchFailure `json:"failed"`
+	Skipped   []string       `json:"skipped,omitempty"`   // done in a previous run, per the manifest
+	Remaining []string       `json:"remaining,omitempty"` // not attempted before --batch-timeout
+}
+
+// batchFailure records a batch entry that could not be downloaded
+type batchFailure struct {
+	Target string `json:"target"`
+	Error  string `json:"error"`
+}
+
+// infof prints a human-readable progress line, unless --json or --quiet is set
+func infof(format string, a ...interface{}) {
+	if jsonOutput || quiet {
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// warnf prints a warning to stderr, unless --quiet is set
+func warnf(format string, a ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: "+format, a...)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "encode JSON output error:", err)
+	}
+}
+
+// printJSONError writes err to stderr as {"error": "..."}
+func printJSONError(err error) {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	fmt.Fprintln(os.Stderr, string(data))
+}
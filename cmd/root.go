@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/baiyutang/tedfetch/internal/parser"
 	"github.com/spf13/cobra"
 )
 
@@ -14,10 +21,124 @@ var rootCmd = &cobra.Command{
 It supports downloading videos in different qualities and subtitles in various languages.`,
 }
 
+var (
+	// Flags shared by every command
+	proxyURL  string
+	timeout   time.Duration
+	userAgent string
+	cacheDir  string
+	cacheTTL  time.Duration
+	verbose   bool
+	quiet     bool
+	// requestDelay is the minimum time between two requests to TED
+	requestDelay time.Duration
+	// baseURL and graphqlURL point the parser at a mirror of TED
+	baseURL    string
+	graphqlURL string
+
+	// httpClient is built from the shared flags before any command runs
+	httpClient *http.Client
+	// logger receives the diagnostics of the parser and the downloader
+	logger *slog.Logger
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&proxyURL, "proxy", "", "Proxy for all requests, e.g. http://host:port or socks5://host:port (default: HTTP_PROXY/HTTPS_PROXY)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Maximum time for each request, including a whole file download, e.g. 30s or 5m (0 means no limit)")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", parser.DefaultUserAgent, "User-Agent header sent with every request")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Directory for caching TED responses between runs (default: no cache)")
+	rootCmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 24*time.Hour, "How long cached responses stay valid")
+	rootCmd.PersistentFlags().DurationVar(&requestDelay, "request-delay", 0, "Minimum time between two requests to TED, e.g. 500ms, to stay polite in batches (0 means no delay)")
+	rootCmd.PersistentFlags().StringVar(&baseURL, "base-url", parser.DefaultBaseURL, "Site root of TED or of a mirror of it, used for talk pages, search and subtitles")
+	rootCmd.PersistentFlags().StringVar(&graphqlURL, "graphql-url", "", "GraphQL endpoint of TED or of a mirror of it (default: <base-url>/graphql)")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log requests, retries and other details to stderr")
+	rootCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "Only report errors, without progress bars or progress messages")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file with default flag values (default: ./.tedfetch.yaml, then ~/.tedfetch.yaml)")
+	rootCmd.PersistentPreRunE = setupClient
+}
+
+// setupClient fills in the flags from the config file and the environment,
+// validates the shared flags and builds httpClient and logger
+func setupClient(cmd *cobra.Command, args []string) error {
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if timeout < 0 {
+		return fmt.Errorf("invalid --timeout %s: must not be negative", timeout)
+	}
+	if requestDelay < 0 {
+		return fmt.Errorf("invalid --request-delay %s: must not be negative", requestDelay)
+	}
+	if err := validateURL("--base-url", baseURL); err != nil {
+		return err
+	}
+	if graphqlURL != "" {
+		if err := validateURL("--graphql-url", graphqlURL); err != nil {
+			return err
+		}
+	}
+	if verbose && quiet {
+		return fmt.Errorf("--verbose cannot be combined with --quiet")
+	}
+	logger = newLogger()
+	if config != "" {
+		logger.Debug("loaded config file", "path", config)
+	}
+
+	client, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+	httpClient = client
+	return nil
+}
+
+// newLogger returns a logger writing to stderr at the level chosen with
+// --verbose or --quiet, without timestamps
+func newLogger() *slog.Logger {
+	level := slog.LevelInfo
+	switch {
+	case verbose:
+		level = slog.LevelDebug
+	case quiet:
+		level = slog.LevelError
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// exitInterrupted is the exit code after Ctrl+C, as shells report for SIGINT
+const exitInterrupted = 130
+
 // Execute adds all child commands to the root command and sets flags appropriately.
+// Ctrl+C cancels the running command, which keeps its partial files for a
+// later resume.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Restore the default handling so a second Ctrl+C exits at once
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		if jsonOutput {
+			printJSONError(err)
+		} else {
+			fmt.Println(err)
+		}
+		if ctx.Err() != nil {
+			os.Exit(exitInterrupted)
+		}
 		os.Exit(1)
 	}
 }
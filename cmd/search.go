@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// searchCmd represents the search command
+	searchCmd = &cobra.Command{
+		Use:   "search",
+		Short: "Search TED talks without downloading",
+		Long: `Search TED talks by topic or title and list the results without downloading. For example:
+tedfetch search education --limit 10
+tedfetch search "The power of vulnerability"
+tedfetch search education --sort newest --max-duration 10m
+tedfetch search --speaker "Brené Brown"`,
+		RunE: runSearch,
+	}
+
+	// Flags
+	searchLimit    int
+	searchSpeaker  string
+	searchSort     string
+	searchMaxDur   time.Duration
+	searchLanguage string
+)
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	// Add flags
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "l", 10, "Maximum number of results")
+	searchCmd.Flags().StringVar(&searchSpeaker, "speaker", "", "List talks given by this speaker instead of searching by topic or title")
+	searchCmd.Flags().StringVar(&searchSort, "sort", "", "Order of the results: newest, oldest, popular or relevance (default: TED's order)")
+	searchCmd.Flags().DurationVar(&searchMaxDur, "max-duration", 0, "Only list talks up to this long, e.g. 10m (0 means no limit)")
+	searchCmd.Flags().StringVar(&searchLanguage, "language", "", "Only list talks available in this language, e.g. es")
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && searchSpeaker == "" {
+		return fmt.Errorf("please provide a topic or title to search for, or --speaker")
+	}
+	if len(args) > 0 && searchSpeaker != "" {
+		return fmt.Errorf("--speaker cannot be combined with a topic or title")
+	}
+	if searchLimit < 1 {
+		return fmt.Errorf("invalid --limit %d: must be at least 1", searchLimit)
+	}
+	filter := parser.TopicFilter{Sort: searchSort, MaxDuration: searchMaxDur, Language: searchLanguage}
+	if searchSpeaker != "" && filter != (parser.TopicFilter{}) {
+		return fmt.Errorf("--sort, --max-duration and --language cannot be combined with --speaker")
+	}
+
+	// Create parser
+	p, err := newParser()
+	if err != nil {
+		return err
+	}
+
+	var talks []parser.Talk
+	if searchSpeaker != "" {
+		talks, err = p.SearchBySpeakerContext(cmd.Context(), searchSpeaker, searchLimit)
+	} else {
+		talks, err = p.ParseTopicFilteredContext(cmd.Context(), strings.Join(args, " "), searchLimit, filter)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to search talks: %w", err)
+	}
+
+	if len(talks) == 0 {
+		fmt.Println("No talks found.")
+		return nil
+	}
+
+	printTalkTable(talks)
+	return nil
+}
+
+// printTalkTable prints talks as a numbered table
+func printTalkTable(talks []parser.Talk) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTITLE\tSPEAKER\tDURATION\tURL")
+	for i, talk := range talks {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, talk.Title, talk.Speaker, talk.Duration, talk.URL)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Println("flush output error:", err)
+	}
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// speakerCmd represents the speaker command
+	speakerCmd = &cobra.Command{
+		Use:   "speaker <name>",
+		Short: "Download all talks given by a speaker",
+		Long: `Search for a speaker's talks and download them into a folder named after the speaker.
+Every download flag applies to each talk. For example:
+tedfetch speaker "Hans Rosling"
+tedfetch speaker "Brené Brown" --limit 5 --quality 480p --subtitle en`,
+		Args: cobra.ExactArgs(1),
+		RunE: runSpeaker,
+	}
+
+	// Flags
+	speakerLimit int
+)
+
+func init() {
+	rootCmd.AddCommand(speakerCmd)
+
+	// Add flags
+	speakerCmd.Flags().IntVarP(&speakerLimit, "limit", "l", 50, "Maximum number of talks to download")
+	// The download flags, without --batch and --filename: the talks come
+	// from the search, and a single file name doesn't fit several talks
+	addDownloadFlags(speakerCmd.Flags())
+}
+
+func runSpeaker(cmd *cobra.Command, args []string) error {
+	name := strings.Join(strings.Fields(args[0]), " ")
+	if name == "" {
+		return fmt.Errorf("please provide a speaker name")
+	}
+	if speakerLimit < 1 {
+		return fmt.Errorf("invalid --limit %d: must be at least 1", speakerLimit)
+	}
+	p, d, err := setupDownload(cmd)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+
+	talks, err := p.SearchBySpeakerContext(ctx, name, speakerLimit)
+	if err != nil {
+		return fmt.Errorf("failed to search talks: %w", err)
+	}
+	if len(talks) == 0 {
+		return fmt.Errorf("no talks found for speaker %q", name)
+	}
+
+	// The default layout already saves each talk in a folder named after its
+	// speaker; the others get one here
+	sub := d
+	if flat || outputTmpl != "" {
+		if sub, err = d.Subdir(name); err != nil {
+			return fmt.Errorf("failed to create speaker directory: %w", err)
+		}
+	}
+
+	urls := make([]string, 0, len(talks))
+	for _, talk := range talks {
+		urls = append(urls, talk.URL)
+	}
+
+	if batchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, batchTimeout)
+		defer cancel()
+	}
+
+	infof("Speaker: %s (%d talks)\n", name, len(urls))
+	result, err := runBatch(ctx, p, sub, urls, nil)
+	if result != nil {
+		result.Speaker = name
+		if jsonOutput {
+			printJSON(result)
+		}
+	}
+	return err
+}
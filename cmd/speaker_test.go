@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// runSpeakerCmd runs "tedfetch speaker args..." against p with every flag
+// reset to its default first
+func runSpeakerCmd(t *testing.T, p parser.TalkParser, args ...string) error {
+	return runCmd(t, context.Background(), speakerCmd, p, args...)
+}
+
+func TestSpeaker(t *testing.T) {
+	server, _ := newFileServer(t)
+	talks := make(map[string]*parser.Talk)
+	for _, slug := range []string{"talk_one", "talk_two", "talk_three"} {
+		talk := &parser.Talk{
+			Title:     slug,
+			Speaker:   "Hans Rosling",
+			URL:       "https://www.ted.com/talks/" + slug,
+			Slug:      slug,
+			VideoURLs: map[string]string{"720p": server.URL + "/720p.mp4"},
+		}
+		// Listed under both its URL and its title
+		talks[talk.URL] = talk
+		talks[talk.Title] = talk
+	}
+	talks["other"] = &parser.Talk{Title: "other", Speaker: "Someone Else", URL: "https://www.ted.com/talks/other", Slug: "other"}
+	p := &fakeParser{talks: talks}
+	dir := t.TempDir()
+
+	err := runSpeakerCmd(t, p, "Hans  Rosling", "--output", dir, "--limit", "5")
+	assert.NoError(t, err)
+	entries, err := os.ReadDir(filepath.Join(dir, "Hans Rosling"))
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	// Every talk of the speaker, within the limit
+	assert.Equal(t, []string{"talk_one", "talk_three", "talk_two"}, names)
+	assert.FileExists(t, filepath.Join(dir, "Hans Rosling", "talk_one", "720p.mp4"))
+
+	// Without the default layout the speaker folder is made here
+	dir = t.TempDir()
+	assert.NoError(t, runSpeakerCmd(t, p, "Hans Rosling", "--output", dir, "--flat"))
+	assert.FileExists(t, filepath.Join(dir, "Hans Rosling", "talk_one-720p.mp4"))
+
+	err = runSpeakerCmd(t, p, "Nobody", "--output", dir)
+	assert.EqualError(t, err, `no talks found for speaker "Nobody"`)
+
+	err = runSpeakerCmd(t, p, "Hans Rosling", "--limit", "0")
+	assert.EqualError(t, err, "invalid --limit 0: must be at least 1")
+}
+
+func TestSpeaker_OwnFlags(t *testing.T) {
+	// Setting a speaker flag doesn't mark the download flag as changed
+	for _, name := range []string{"quality", "output", "embed-subtitles", "json"} {
+		assert.NotNil(t, speakerCmd.Flags().Lookup(name), name)
+		assert.NotSame(t, downloadCmd.Flags().Lookup(name), speakerCmd.Flags().Lookup(name), name)
+	}
+	assert.Equal(t, "all", speakerCmd.Flags().Lookup("embed-subtitles").NoOptDefVal)
+	for _, name := range []string{"batch", "filename"} {
+		assert.Nil(t, speakerCmd.Flags().Lookup(name), name)
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// topicsCmd represents the topics command
+var topicsCmd = &cobra.Command{
+	Use:   "topics",
+	Short: "List the TED topics that can be searched",
+	Long: `List TED's topics with the slug to pass to search. For example:
+tedfetch topics
+tedfetch search technology`,
+	Args: cobra.NoArgs,
+	RunE: runTopics,
+}
+
+func init() {
+	rootCmd.AddCommand(topicsCmd)
+}
+
+func runTopics(cmd *cobra.Command, args []string) error {
+	p, err := newParser()
+	if err != nil {
+		return err
+	}
+
+	topics, err := p.ListTopicsContext(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SLUG\tNAME")
+	for _, topic := range topics {
+		fmt.Fprintf(w, "%s\t%s\n", topic.Slug, topic.Name)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Println("flush output error:", err)
+	}
+	return nil
+}
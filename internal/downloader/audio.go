@@ -0,0 +1,149 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// lookFFmpeg finds the ffmpeg binary; replaced in tests
+var lookFFmpeg = func() (string, error) {
+	return exec.LookPath("ffmpeg")
+}
+
+// FindFFmpeg returns the path of the ffmpeg binary on PATH
+func FindFFmpeg() (string, error) {
+	path, err := lookFFmpeg()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg not found on PATH: %w", err)
+	}
+	return path, nil
+}
+
+// AudioSource tells where DownloadAudio gets a talk's audio from
+type AudioSource string
+
+const (
+	// AudioFromTED is TED's own audio file, the talk's AudioURL
+	AudioFromTED AudioSource = "ted"
+	// AudioFromVideo is the audio track of the talk's smallest video,
+	// extracted with ffmpeg
+	AudioFromVideo AudioSource = "video"
+)
+
+// SelectAudio returns where DownloadAudio gets the audio of talk from and
+// the URL it downloads: TED's audio file or, when TED offers none and ffmpeg
+// is on PATH, the talk's smallest video
+func SelectAudio(talk *parser.Talk) (AudioSource, string, error) {
+	if talk.AudioURL != "" {
+		return AudioFromTED, talk.AudioURL, nil
+	}
+	videoURL := smallestVideo(talk)
+	if videoURL == "" {
+		return "", "", fmt.Errorf("audio not available for this talk")
+	}
+	if _, err := FindFFmpeg(); err != nil {
+		return "", "", fmt.Errorf("audio not available for this talk; install ffmpeg to extract it from the video")
+	}
+	return AudioFromVideo, videoURL, nil
+}
+
+// smallestVideo returns the URL of the talk's lowest resolution video, or ""
+// if it has none
+func smallestVideo(talk *parser.Talk) string {
+	urls := make(map[string]string, len(talk.VideoURLs))
+	for _, format := range talk.VideoFormats {
+		if format.URL != "" {
+			urls[format.Quality] = format.URL
+		}
+	}
+	for quality, url := range talk.VideoURLs {
+		if url != "" {
+			urls[quality] = url
+		}
+	}
+	smallest, height := "", 0
+	for quality, url := range urls {
+		h := parser.QualityHeight(quality)
+		if h == 0 {
+			continue
+		}
+		if smallest == "" || h < height {
+			smallest, height = url, h
+		}
+	}
+	return smallest
+}
+
+// downloadAudio implements DownloadAudio, reporting the bytes to progress
+func (d *Downloader) downloadAudio(ctx context.Context, talk *parser.Talk, filename string, progress func(offset, length int64) io.Writer) (AudioSource, error) {
+	source, url, err := SelectAudio(talk)
+	if err != nil {
+		return "", err
+	}
+	if source == AudioFromVideo {
+		d.log().Info("extracting audio from video, TED offers no audio file", "url", url, "path", filename)
+		return source, d.extractAudio(ctx, url, expectedChecksum(talk, url), filename, progress)
+	}
+	return source, d.download(ctx, url, filename, JobAudio, "", progress)
+}
+
+// extractAudio downloads the video at videoURL, checked against sum like
+// download does, and extracts its audio track into the mp3 filename with
+// ffmpeg, reporting the video download to progress. The downloaded video is
+// removed afterwards.
+func (d *Downloader) extractAudio(ctx context.Context, videoURL, sum, filename string, progress func(offset, length int64) io.Writer) error {
+	ffmpeg, err := FindFFmpeg()
+	if err != nil {
+		return err
+	}
+
+	// The remote size is the video's, so completeness can't be checked
+	// against it; an existing mp3 is taken as done
+	if !d.overwriteFor(JobAudio) && fileSize(filename) > 0 {
+		d.log().Info("skipping file, already downloaded", "path", filename)
+		return d.recordExistingChecksum(filename)
+	}
+
+	video := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".source.mp4"
+	if err := d.download(ctx, videoURL, video, JobVideo, sum, progress); err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(video); err != nil && !os.IsNotExist(err) {
+			d.log().Warn("failed to remove video", "path", video, "error", err)
+		}
+	}()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-y", "-loglevel", "error", "-i", video, "-vn", "-codec:a", "libmp3lame", "-q:a", "2", filename)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		d.removePartial(filename)
+		if ctx.Err() != nil {
+			return fmt.Errorf("download cancelled: %w", ctx.Err())
+		}
+		return fmt.Errorf("failed to extract audio with ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return d.recordExistingChecksum(filename)
+}
+
+// recordExistingChecksum records the checksum of a file already on disk,
+// if checksums are written
+func (d *Downloader) recordExistingChecksum(filename string) error {
+	if !d.writeChecksum {
+		return nil
+	}
+	sum, err := fileChecksum(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+	return d.recordChecksum(filename, sum)
+}
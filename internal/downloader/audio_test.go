@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFFmpeg makes lookFFmpeg return a script that writes "audio of " and
+// the input file's content to the output file, its last argument
+func fakeFFmpeg(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	script := filepath.Join(t.TempDir(), "ffmpeg")
+	err := os.WriteFile(script, []byte(`#!/bin/sh
+while [ "$1" != "-i" ]; do shift; done
+input="$2"
+for out; do :; done
+{ printf 'audio of '; cat "$input"; } > "$out"
+`), 0755)
+	assert.NoError(t, err)
+
+	saved := lookFFmpeg
+	lookFFmpeg = func() (string, error) { return script, nil }
+	t.Cleanup(func() { lookFFmpeg = saved })
+}
+
+func TestDownloadAudio_ExtractsFromVideo(t *testing.T) {
+	fakeFFmpeg(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	// TED offers no audio file, so it comes from the smallest video
+	talk := &parser.Talk{
+		VideoURLs:    map[string]string{"720p": server.URL + "/720p.mp4"},
+		VideoFormats: []parser.VideoFormat{{Quality: "360p", URL: server.URL + "/360p.mp4"}},
+	}
+
+	filename := filepath.Join(tempDir, "talk", "audio.mp3")
+	source, err := d.DownloadAudio(talk, filename)
+	assert.NoError(t, err)
+	assert.Equal(t, AudioFromVideo, source)
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "audio of /360p.mp4", string(content))
+	// The downloaded video is not kept
+	assert.NoFileExists(t, filepath.Join(tempDir, "talk", "audio.source.mp4"))
+
+	// Works as a batch job without a URL like any other file
+	filename = filepath.Join(tempDir, "batch", "audio.mp3")
+	errs := d.DownloadBatch([]DownloadJob{{Filename: filename, Type: JobAudio, Talk: talk}}, 1)
+	assert.NoError(t, errs[0])
+	content, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "audio of /360p.mp4", string(content))
+}
+
+func TestSelectAudio(t *testing.T) {
+	fakeFFmpeg(t)
+	talk := &parser.Talk{
+		AudioURL:  "https://example.com/audio.mp3",
+		VideoURLs: map[string]string{"720p": "https://example.com/720p.mp4", "360p": "https://example.com/360p.mp4"},
+	}
+	source, url, err := SelectAudio(talk)
+	assert.NoError(t, err)
+	assert.Equal(t, AudioFromTED, source)
+	assert.Equal(t, "https://example.com/audio.mp3", url)
+
+	talk.AudioURL = ""
+	source, url, err = SelectAudio(talk)
+	assert.NoError(t, err)
+	assert.Equal(t, AudioFromVideo, source)
+	assert.Equal(t, "https://example.com/360p.mp4", url)
+
+	_, _, err = SelectAudio(&parser.Talk{})
+	assert.EqualError(t, err, "audio not available for this talk")
+
+	lookFFmpeg = func() (string, error) { return "", errors.New("not found") }
+	_, _, err = SelectAudio(talk)
+	assert.EqualError(t, err, "audio not available for this talk; install ffmpeg to extract it from the video")
+}
@@ -0,0 +1,144 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// JobType identifies what kind of file a DownloadJob fetches
+type JobType string
+
+const (
+	JobVideo    JobType = "video"
+	JobSubtitle JobType = "subtitle"
+	// JobAudio downloads the audio file at URL or, when URL is empty, the
+	// audio of Talk like DownloadAudio does
+	JobAudio JobType = "audio"
+)
+
+// DownloadJob describes a single file to fetch with DownloadBatch
+type DownloadJob struct {
+	URL      string
+	Filename string
+	Type     JobType
+	// Content, when not nil, is saved to Filename instead of downloading
+	// URL, e.g. subtitles the parser converted to SRT
+	Content []byte
+	// Talk, when set, is passed to the OnComplete hook
+	Talk *parser.Talk
+}
+
+// DownloadBatch downloads jobs using up to concurrency parallel workers.
+// The returned slice holds one error (or nil) per job, in the same order.
+// With concurrency > 1 a single aggregate progress bar is rendered so
+// parallel downloads don't garble each other's output.
+func (d *Downloader) DownloadBatch(jobs []DownloadJob, concurrency int) []error {
+	return d.DownloadBatchContext(context.Background(), jobs, concurrency)
+}
+
+// DownloadBatchContext is like DownloadBatch but aborts every transfer when
+// ctx is cancelled; jobs that had not started report the cancellation error
+func (d *Downloader) DownloadBatchContext(ctx context.Context, jobs []DownloadJob, concurrency int) []error {
+	errs := make([]error, len(jobs))
+	if len(jobs) == 0 {
+		return errs
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+
+	// Serial downloads keep the familiar per-file progress bars
+	if concurrency == 1 {
+		for i, job := range jobs {
+			errs[i] = d.run(ctx, job, d.progressFor(job.Type))
+		}
+		return errs
+	}
+
+	// Parallel downloads share one aggregate bar, or one aggregate count
+	// reported to the progress handler
+	aggregate := &aggregateProgress{handler: d.progress}
+	var finish func() error
+	if d.progress == nil {
+		aggregate.bar = newSpinner(fmt.Sprintf("Downloading %d files", len(jobs)))
+		finish = aggregate.bar.Finish
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				job := jobs[i]
+				errs[i] = d.run(ctx, job, aggregate.forJob())
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if finish != nil {
+		if err := finish(); err != nil {
+			d.log().Warn("failed to finish progress bar", "error", err)
+		}
+	}
+
+	return errs
+}
+
+// run fetches a single job, reporting its bytes to progress, and calls the
+// OnComplete hook once it finished
+func (d *Downloader) run(ctx context.Context, job DownloadJob, progress func(offset, length int64) io.Writer) error {
+	var err error
+	switch {
+	case job.Content != nil:
+		err = d.save(ctx, job.Content, job.Filename, job.Type, progress)
+	case job.Type == JobAudio && job.URL == "" && job.Talk != nil:
+		_, err = d.downloadAudio(ctx, job.Talk, job.Filename, progress)
+	default:
+		err = d.download(ctx, job.URL, job.Filename, job.Type, expectedChecksum(job.Talk, job.URL), progress)
+	}
+	return d.completed(err, job.Filename, job.Talk)
+}
+
+// save writes content to filename like a download of kind would: an existing
+// file is kept unless overwriting, and the checksum is recorded
+func (d *Downloader) save(ctx context.Context, content []byte, filename string, kind JobType, progress func(offset, length int64) io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("download cancelled: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if !d.overwriteFor(kind) && len(content) > 0 && fileSize(filename) == int64(len(content)) {
+		d.log().Info("skipping file, already downloaded", "path", filename)
+		return d.recordExistingChecksum(filename)
+	}
+
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		d.removePartial(filename)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if _, err := progress(0, int64(len(content))).Write(content); err != nil {
+		d.log().Warn("failed to report progress", "error", err)
+	}
+	sum := sha256.Sum256(content)
+	return d.recordChecksum(filename, hex.EncodeToString(sum[:]))
+}
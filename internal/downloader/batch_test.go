@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	jobs := []DownloadJob{
+		{URL: server.URL + "/a", Filename: filepath.Join(tempDir, "talk", "720p.mp4"), Type: JobVideo},
+		{URL: server.URL + "/b", Filename: filepath.Join(tempDir, "talk", "en.srt"), Type: JobSubtitle},
+		{URL: server.URL + "/missing", Filename: filepath.Join(tempDir, "talk", "fr.srt"), Type: JobSubtitle},
+		{URL: server.URL + "/c", Filename: filepath.Join(tempDir, "talk", "zh-cn.srt"), Type: JobSubtitle},
+	}
+
+	errs := d.DownloadBatch(jobs, 2)
+	assert.Len(t, errs, len(jobs))
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Error(t, errs[2])
+	assert.NoError(t, errs[3])
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+
+	for i, path := range []string{"/a", "/b", "", "/c"} {
+		if path == "" {
+			continue
+		}
+		content, err := os.ReadFile(jobs[i].Filename)
+		assert.NoError(t, err)
+		assert.Equal(t, "content of "+path, string(content))
+	}
+}
+
+func TestDownloadBatch_Serial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("test content"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	errs := d.DownloadBatch([]DownloadJob{
+		{URL: server.URL, Filename: filepath.Join(tempDir, "a.mp4"), Type: JobVideo},
+	}, 0)
+	assert.Equal(t, []error{nil}, errs)
+	assert.Empty(t, d.DownloadBatch(nil, 4))
+}
+
+func TestDownloadBatch_Content(t *testing.T) {
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	// Content is saved without any request to URL
+	filename := filepath.Join(tempDir, "talk", "en.srt")
+	job := DownloadJob{URL: "http://127.0.0.1:0/en.srt", Filename: filename, Type: JobSubtitle, Content: []byte("subtitles")}
+	errs := d.DownloadBatch([]DownloadJob{job}, 1)
+	assert.NoError(t, errs[0])
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "subtitles", string(content))
+
+	// Other content replaces the file
+	job.Content = []byte("new subtitles")
+	errs = d.DownloadBatch([]DownloadJob{job}, 1)
+	assert.NoError(t, errs[0])
+	content, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "new subtitles", string(content))
+}
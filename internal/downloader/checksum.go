@@ -0,0 +1,91 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+)
+
+// checksumSuffix is appended to a downloaded file's name for its SHA-256 sidecar
+const checksumSuffix = ".sha256"
+
+// SetChecksum enables writing a "<file>.sha256" sidecar after each download.
+// When enabled, files whose existing sidecar still matches are not downloaded again.
+func (d *Downloader) SetChecksum(enabled bool) {
+	d.writeChecksum = enabled
+}
+
+// Checksum returns the hex SHA-256 of the last successful download of filename,
+// or an empty string if it hasn't been downloaded. Skipped complete files only
+// have a checksum when checksums are enabled.
+func (d *Downloader) Checksum(filename string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.checksums[filename]
+}
+
+// recordChecksum remembers sum for filename and writes the sidecar if enabled
+func (d *Downloader) recordChecksum(filename, sum string) error {
+	d.mu.Lock()
+	d.checksums[filename] = sum
+	d.mu.Unlock()
+
+	if !d.writeChecksum {
+		return nil
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(filename))
+	if err := os.WriteFile(filename+checksumSuffix, []byte(line), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum file: %w", err)
+	}
+	return nil
+}
+
+// ErrChecksumMismatch is returned when a downloaded file doesn't match the
+// SHA-256 the talk gives for it
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// expectedChecksum returns the SHA-256 talk gives for the video at url, or
+// "" if there is none
+func expectedChecksum(talk *parser.Talk, url string) string {
+	if talk == nil {
+		return ""
+	}
+	for _, format := range talk.VideoFormats {
+		if format.URL == url {
+			return format.SHA256
+		}
+	}
+	return ""
+}
+
+// verifiedChecksum returns the checksum of filename if it matches its sidecar
+func verifiedChecksum(filename string) (string, bool) {
+	data, err := os.ReadFile(filename + checksumSuffix)
+	if err != nil {
+		return "", false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", false
+	}
+	sum, err := fileChecksum(filename)
+	if err != nil || !strings.EqualFold(sum, fields[0]) {
+		return "", false
+	}
+	return sum, true
+}
+
+// fileChecksum computes the hex SHA-256 of the file at path
+func fileChecksum(path string) (string, error) {
+	h := sha256.New()
+	if err := hashFile(h, path); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChecksum(t *testing.T) {
+	content := []byte("test content")
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.SetChecksum(true)
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, sha256Hex(content), d.Checksum(filename))
+
+	sidecar, err := os.ReadFile(filename + ".sha256")
+	assert.NoError(t, err)
+	assert.Equal(t, sha256Hex(content)+"  720p.mp4\n", string(sidecar))
+
+	// An intact file is not downloaded again
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 1, calls)
+
+	// A corrupted file is
+	assert.NoError(t, os.WriteFile(filename, []byte("corrupt"), 0644))
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 2, calls)
+}
+
+func TestChecksum_CoversResumedFile(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:10])
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(content[10:])
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, sha256Hex(content), d.Checksum(filename))
+
+	// No sidecar unless enabled
+	_, err = os.Stat(filename + ".sha256")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,157 +1,581 @@
 package downloader
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"github.com/baiyutang/tedfetch/internal/logging"
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/baiyutang/tedfetch/internal/retry"
 )
 
 // Downloader handles downloading of TED talk videos and subtitles
 type Downloader struct {
-	client *http.Client
+	client    *http.Client
+	userAgent string
 	// Base directory for downloads
 	baseDir    string
 	maxRetries int
+	limiter    *rateLimiter
+	overwrite  bool
+	// overwriteKinds overrides overwrite per file type, see SetOverwriteFor
+	overwriteKinds map[JobType]bool
+	// keepPartial keeps the file of a failed download for a later resume
+	keepPartial bool
+	// nameTemplate lays out downloads, see SetNameTemplate
+	nameTemplate *template.Template
+	// layout places downloads without a name template, see SetLayout
+	layout Layout
+	// progress receives download progress instead of the terminal progress bar
+	progress func(downloaded, total int64)
+	// onComplete is called for every finished file, see OnComplete
+	onComplete func(path string, talk *parser.Talk)
+	// sleep waits between retries; replaced by a fake clock in tests
+	sleep func(ctx context.Context, d time.Duration) error
+	// retryDelay is the backoff before the first retry, see retry.Delay
+	retryDelay time.Duration
+	// Checksums of completed downloads, keyed by filename
+	writeChecksum bool
+	checksums     map[string]string
+	mu            sync.Mutex
+	// logger receives diagnostics, see SetLogger
+	logger *slog.Logger
 }
 
 // New creates a new Downloader instance
 func New(baseDir string) (*Downloader, error) {
+	return NewWithClient(baseDir, defaultClient())
+}
+
+// NewWithClient creates a new Downloader that sends every request through
+// client, e.g. to set timeouts, a proxy or a custom transport.
+// A nil client falls back to the default one.
+func NewWithClient(baseDir string, client *http.Client) (*Downloader, error) {
+	if client == nil {
+		client = defaultClient()
+	}
+
 	// Create base directory if it doesn't exist
 	if err := os.MkdirAll(baseDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create base directory: %w", err)
 	}
 
 	return &Downloader{
-		client:     &http.Client{},
+		client:     client,
+		userAgent:  DefaultUserAgent,
 		baseDir:    baseDir,
 		maxRetries: 3,
+		sleep:      retry.Sleep,
+		retryDelay: time.Second,
+		checksums:  make(map[string]string),
 	}, nil
 }
 
-// DownloadVideo downloads a video file with progress bar
-func (d *Downloader) DownloadVideo(url, filename string) error {
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+// DefaultUserAgent is a desktop browser User-Agent, the same one the parser sends
+const DefaultUserAgent = parser.DefaultUserAgent
+
+// defaultClient returns a client that gives up on unresponsive servers.
+// There is no overall timeout, since large videos can take a long time.
+func defaultClient() *http.Client {
+	return &http.Client{Transport: NewTransport()}
+}
+
+// NewTransport returns a transport that gives up on servers that don't
+// accept the connection, finish the TLS handshake or send response headers
+// in time, for clients passed to NewWithClient
+func NewTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return transport
+}
+
+// Subdir returns a Downloader with the same settings that saves into the
+// named subdirectory of d's base directory. The name is sanitized.
+func (d *Downloader) Subdir(name string) (*Downloader, error) {
+	sub, err := NewWithClient(filepath.Join(d.baseDir, sanitizeFilename(name)), d.client)
+	if err != nil {
+		return nil, err
 	}
+	sub.maxRetries = d.maxRetries
+	sub.sleep = d.sleep
+	sub.limiter = d.limiter
+	sub.overwrite = d.overwrite
+	sub.overwriteKinds = maps.Clone(d.overwriteKinds)
+	sub.keepPartial = d.keepPartial
+	sub.userAgent = d.userAgent
+	sub.nameTemplate = d.nameTemplate
+	sub.layout = d.layout
+	sub.progress = d.progress
+	sub.onComplete = d.onComplete
+	sub.writeChecksum = d.writeChecksum
+	sub.logger = d.logger
+	return sub, nil
+}
+
+// SetClient replaces the HTTP client used for downloads, e.g. to set a
+// timeout, a proxy or a custom transport. A nil client is ignored.
+func (d *Downloader) SetClient(client *http.Client) {
+	if client != nil {
+		d.client = client
+	}
+}
+
+// SetUserAgent sets the User-Agent sent with every request.
+// An empty value restores DefaultUserAgent.
+func (d *Downloader) SetUserAgent(userAgent string) {
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	d.userAgent = userAgent
+}
+
+// SetRateLimit caps the combined download speed in bytes per second.
+// A value of 0 or less means unlimited.
+func (d *Downloader) SetRateLimit(bytesPerSec int64) {
+	if bytesPerSec <= 0 {
+		d.limiter = nil
+		return
+	}
+	d.limiter = newRateLimiter(bytesPerSec)
+}
+
+// SetOverwrite makes downloads replace existing files even when they are
+// already complete. By default complete files are skipped.
+func (d *Downloader) SetOverwrite(overwrite bool) {
+	d.overwrite = overwrite
+}
+
+// SetOverwriteFor overrides SetOverwrite for files of kind, e.g. to always
+// refresh subtitles but keep existing videos
+func (d *Downloader) SetOverwriteFor(kind JobType, overwrite bool) {
+	if d.overwriteKinds == nil {
+		d.overwriteKinds = make(map[JobType]bool)
+	}
+	d.overwriteKinds[kind] = overwrite
+}
+
+// overwriteFor reports whether complete files of kind are downloaded again
+func (d *Downloader) overwriteFor(kind JobType) bool {
+	if overwrite, ok := d.overwriteKinds[kind]; ok {
+		return overwrite
+	}
+	return d.overwrite
+}
+
+// SetMaxRetries sets how many times a download is attempted before giving up.
+// Values below 1 mean a single attempt.
+func (d *Downloader) SetMaxRetries(n int) {
+	if n < 1 {
+		n = 1
+	}
+	d.maxRetries = n
+}
+
+// SetKeepPartial keeps the ".part" file of a download that failed after every
+// retry, so it can be resumed later. By default it is removed. The ".part"
+// file of a cancelled download is always kept.
+func (d *Downloader) SetKeepPartial(keep bool) {
+	d.keepPartial = keep
+}
 
+// SetProgressHandler routes download progress to handler instead of the
+// terminal progress bar. downloaded and total count bytes of the whole file;
+// total is -1 when the server doesn't send a length. A nil handler restores
+// the progress bar.
+func (d *Downloader) SetProgressHandler(handler func(downloaded, total int64)) {
+	d.progress = handler
+}
+
+// isComplete reports whether filename already holds the full content of url.
+// A checksum sidecar is authoritative when checksums are enabled; otherwise
+// the file size is compared with the Content-Length of a HEAD request.
+func (d *Downloader) isComplete(ctx context.Context, url, filename string) bool {
+	info, err := os.Stat(filename)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	if d.writeChecksum {
+		if _, err := os.Stat(filename + checksumSuffix); err == nil {
+			_, ok := verifiedChecksum(filename)
+			return ok
+		}
+	}
+
+	// Without a Content-Length, e.g. for chunked responses, the size can't
+	// be compared and the file is downloaded again
+	size, err := d.RemoteSizeContext(ctx, url)
+	if err == nil && size < 0 {
+		d.log().Debug("remote size unknown, downloading again", "path", filename)
+	}
+	return err == nil && size >= 0 && size == info.Size()
+}
+
+// RemoteSize issues a HEAD request for url and returns its Content-Length,
+// or -1 if the server doesn't report it
+func (d *Downloader) RemoteSize(url string) (int64, error) {
+	return d.RemoteSizeContext(context.Background(), url)
+}
+
+// RemoteSizeContext is like RemoteSize but aborts when ctx is cancelled
+func (d *Downloader) RemoteSizeContext(ctx context.Context, url string) (int64, error) {
+	resp, err := d.head(ctx, url)
+	if err != nil {
+		return -1, err
+	}
+	return resp.ContentLength, nil
+}
+
+// StatusError is returned (wrapped) when a server answers a request with a
+// status other than 200 OK; retrieve it with errors.As
+type StatusError struct {
+	Code   int
+	Status string // e.g. "403 Forbidden"
+}
+
+func (e *StatusError) Error() string {
+	return "bad status: " + e.Status
+}
+
+// head sends a HEAD request for url, retried like a download on network
+// errors and on 429 and 5xx responses, and returns the 200 OK response
+// with its body closed
+func (d *Downloader) head(ctx context.Context, url string) (*http.Response, error) {
 	var lastErr error
+	var wait time.Duration
+	hasWait := false // whether the last response asked for wait with Retry-After
 	for attempt := 0; attempt < d.maxRetries; attempt++ {
-		// Create output file (truncate if exists)
-		out, err := os.Create(filename)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
+		if attempt > 0 {
+			delay := retry.Delay(d.retryDelay, attempt-1)
+			if hasWait {
+				delay, hasWait = wait, false
+			}
+			if err := d.sleep(ctx, delay); err != nil {
+				return nil, err
+			}
 		}
 
-		resp, err := d.client.Get(url)
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
 		if err != nil {
-			_ = out.Close()
-			lastErr = fmt.Errorf("failed to get video: %w", err)
-			continue
+			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			if cerr := resp.Body.Close(); cerr != nil {
-				fmt.Println("close response body error:", cerr)
+		req.Header.Set("User-Agent", d.userAgent)
+		resp, err := d.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
 			}
-			_ = out.Close()
-			lastErr = fmt.Errorf("bad status: %s", resp.Status)
+			lastErr = fmt.Errorf("failed to send request: %w", err)
 			continue
 		}
+		logging.CloseBody(d.log(), resp.Body)
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		lastErr = &StatusError{Code: resp.StatusCode, Status: resp.Status}
+		if !retry.Retryable(resp.StatusCode) {
+			break
+		}
+		wait, hasWait = retry.After(resp)
+	}
+	return nil, lastErr
+}
 
-		bar := progressbar.DefaultBytes(
-			resp.ContentLength,
-			"Downloading video",
-		)
+// FillVideoSizes sets the Size of each of talk's VideoFormats that has
+// none, such as those of talks resolved through GraphQL, with a HEAD request
+// per video. Sizes the server doesn't report stay 0.
+func (d *Downloader) FillVideoSizes(talk *parser.Talk) {
+	d.FillVideoSizesContext(context.Background(), talk)
+}
 
-		_, err = io.Copy(io.MultiWriter(out, bar), resp.Body)
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Println("close response body error:", cerr)
+// FillVideoSizesContext is like FillVideoSizes but stops when ctx is cancelled
+func (d *Downloader) FillVideoSizesContext(ctx context.Context, talk *parser.Talk) {
+	for i := range talk.VideoFormats {
+		format := &talk.VideoFormats[i]
+		if format.Size > 0 || ctx.Err() != nil {
+			continue
 		}
+		size, err := d.RemoteSizeContext(ctx, format.URL)
 		if err != nil {
-			_ = out.Close()
-			lastErr = fmt.Errorf("failed to download video: %w", err)
+			d.log().Debug("failed to get the video size", "quality", format.Quality, "error", err)
 			continue
 		}
-
-		if cerr := out.Close(); cerr != nil {
-			fmt.Println("close file error:", cerr)
+		if size > 0 {
+			format.Size = size
 		}
-
-		return nil
 	}
+}
 
-	return lastErr
+// DownloadVideo downloads a video file with progress bar
+func (d *Downloader) DownloadVideo(url, filename string) error {
+	return d.DownloadVideoContext(context.Background(), url, filename)
+}
+
+// DownloadVideoContext is like DownloadVideo but aborts the transfer and
+// removes the partial file when ctx is cancelled
+func (d *Downloader) DownloadVideoContext(ctx context.Context, url, filename string) error {
+	return d.completed(d.download(ctx, url, filename, JobVideo, "", d.progressFor(JobVideo)), filename, nil)
 }
 
 // DownloadSubtitle downloads a subtitle file
 func (d *Downloader) DownloadSubtitle(url, filename string) error {
+	return d.DownloadSubtitleContext(context.Background(), url, filename)
+}
+
+// DownloadSubtitleContext is like DownloadSubtitle but aborts when ctx is cancelled
+func (d *Downloader) DownloadSubtitleContext(ctx context.Context, url, filename string) error {
+	return d.completed(d.download(ctx, url, filename, JobSubtitle, "", d.progressFor(JobSubtitle)), filename, nil)
+}
+
+// DownloadAudio downloads the audio-only file of talk with progress bar.
+// When TED offers none and ffmpeg is on PATH, the talk's smallest video is
+// downloaded instead and its audio track extracted into filename; the video
+// is removed afterwards. The returned source tells which was used, see
+// SelectAudio.
+func (d *Downloader) DownloadAudio(talk *parser.Talk, filename string) (AudioSource, error) {
+	return d.DownloadAudioContext(context.Background(), talk, filename)
+}
+
+// DownloadAudioContext is like DownloadAudio but aborts when ctx is cancelled
+func (d *Downloader) DownloadAudioContext(ctx context.Context, talk *parser.Talk, filename string) (AudioSource, error) {
+	source, err := d.downloadAudio(ctx, talk, filename, d.progressFor(JobAudio))
+	return source, d.completed(err, filename, talk)
+}
+
+// partSuffix is appended to a file's name while it is being downloaded
+const partSuffix = ".part"
+
+// download fetches url into filename, retrying on failure.
+// kind describes the file in error messages and progress returns the
+// writer that receives a copy of the downloaded bytes, given the bytes
+// already on disk and the length of the response (-1 if unknown).
+// The bytes are streamed into filename+".part" like StreamVideo does, and
+// the file is renamed to filename once stream checked its size and, when
+// sum isn't empty, its SHA-256 matches sum. A ".part" file left by an
+// earlier run is resumed with a Range request. When every attempt fails,
+// the ".part" file is removed unless keepPartial is set; when ctx is
+// cancelled it is kept for the next run.
+func (d *Downloader) download(ctx context.Context, url, filename string, kind JobType, sum string, progress func(offset, length int64) io.Writer) error {
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	var lastErr error
-	for attempt := 0; attempt < d.maxRetries; attempt++ {
-		out, err := os.Create(filename)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
-		}
+	// Skip files that are already complete
+	if !d.overwriteFor(kind) && d.isComplete(ctx, url, filename) {
+		d.log().Info("skipping file, already downloaded", "path", filename)
+		return d.recordExistingChecksum(filename)
+	}
 
-		resp, err := d.client.Get(url)
-		if err != nil {
-			_ = out.Close()
-			lastErr = fmt.Errorf("failed to get subtitle: %w", err)
-			continue
+	part := filename + partSuffix
+	created := fileSize(part) == 0 // whether no earlier run left bytes to keep
+	out, err := openPartFile(part)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if out.size > 0 {
+		d.log().Info("resuming partial download", "path", part, "offset", out.size)
+	}
+	err = d.stream(ctx, url, out, out.size, kind, progress)
+	if cerr := out.f.Close(); cerr != nil && err == nil {
+		err = fmt.Errorf("failed to write %s: %w", kind, cerr)
+	}
+	if err != nil {
+		if ctx.Err() == nil && (created || out.changed) {
+			d.removePartial(part)
 		}
+		return err
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			if cerr := resp.Body.Close(); cerr != nil {
-				fmt.Println("close response body error:", cerr)
-			}
-			_ = out.Close()
-			lastErr = fmt.Errorf("bad status: %s", resp.Status)
-			continue
+	got := hex.EncodeToString(out.hash.Sum(nil))
+	if sum != "" && !strings.EqualFold(got, sum) {
+		// The bytes can't be trusted, so there is nothing to resume
+		if err := os.Remove(part); err != nil {
+			d.log().Warn("failed to remove partial file", "path", part, "error", err)
 		}
+		return fmt.Errorf("failed to download %s: %w: got %s, want %s", kind, ErrChecksumMismatch, got, sum)
+	}
+	if err := os.Rename(part, filename); err != nil {
+		return fmt.Errorf("failed to finalize %s: %w", kind, err)
+	}
+	return d.recordChecksum(filename, got)
+}
 
-		bar := progressbar.DefaultBytes(
-			resp.ContentLength,
-			"Downloading subtitle",
-		)
+// partFile is the ".part" file a download is written to. It hashes the
+// whole file, including the bytes an earlier run left, and can start over.
+type partFile struct {
+	f    *os.File
+	hash hash.Hash
+	size int64 // bytes in the file when it was opened
+	// changed reports whether this download wrote to the file
+	changed bool
+}
 
-		_, err = io.Copy(io.MultiWriter(out, bar), resp.Body)
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Println("close response body error:", cerr)
-		}
-		if err != nil {
-			_ = out.Close()
-			lastErr = fmt.Errorf("failed to download subtitle: %w", err)
-			continue
-		}
+// openPartFile opens path for appending, creating it if needed
+func openPartFile(path string) (*partFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	p := &partFile{f: f, hash: sha256.New()}
+	if p.size, err = io.Copy(p.hash, f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to read partial file: %w", err)
+	}
+	return p, nil
+}
 
-		if cerr := out.Close(); cerr != nil {
-			fmt.Println("close file error:", cerr)
-		}
+func (p *partFile) Write(b []byte) (int, error) {
+	p.changed = true
+	n, err := p.f.Write(b)
+	p.hash.Write(b[:n])
+	return n, err
+}
+
+// Reset empties the file, so stream starts the download over
+func (p *partFile) Reset() error {
+	p.changed = true
+	p.hash.Reset()
+	if err := p.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := p.f.Seek(0, io.SeekStart)
+	return err
+}
+
+// cancelled returns the cancellation error of a download. Its ".part" file
+// is kept, so running the same download again resumes it.
+func (d *Downloader) cancelled(ctx context.Context) error {
+	return fmt.Errorf("download cancelled: %w", ctx.Err())
+}
+
+// removePartial deletes the incomplete file of a failed download unless
+// partial files are kept
+func (d *Downloader) removePartial(filename string) {
+	if d.keepPartial {
+		return
+	}
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		d.log().Warn("failed to remove partial file", "path", filename, "error", err)
+	}
+}
+
+// newRequest creates a GET request for url, asking for the bytes from offset
+// on when offset is positive, so a download resumes where the previous
+// attempt stopped
+func (d *Downloader) newRequest(ctx context.Context, url string, offset int64) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", d.userAgent)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return req, nil
+}
+
+// body wraps a response body so reading it honors the rate limit and stops
+// once ctx is cancelled
+func (d *Downloader) body(ctx context.Context, r io.Reader) io.Reader {
+	if d.limiter != nil {
+		r = &throttledReader{r: r, limiter: d.limiter}
+	}
+	return &contextReader{ctx: ctx, r: r}
+}
+
+// contextReader stops reading from r once ctx is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
 
-		return nil
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// contentRange returns the first byte position and the size of the whole
+// file of a "bytes start-end/total" header. total is -1 when it is "*".
+func contentRange(header string) (start, total int64, ok bool) {
+	var end int64
+	var size string
+	if _, err := fmt.Sscanf(header, "bytes %d-%d/%s", &start, &end, &size); err != nil {
+		return 0, 0, false
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || total < 0 {
+		total = -1
 	}
+	return start, total, true
+}
+
+// hashFile feeds the contents of the file at path into w
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	// Closing a file that was only read can't lose data
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(w, f)
+	return err
+}
 
-	return lastErr
+// fileSize returns the size of the file at path, or 0 if it can't be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
 }
 
-// GetDownloadPath returns the full path for a download
-func (d *Downloader) GetDownloadPath(talkTitle, format string) string {
+// GetDownloadPath returns the full path for a download: <slug>/<format>, or
+// <slug>-<format> with LayoutFlat. Pass the talk's slug rather than its
+// title: TED reuses titles across speakers, so titles can collide.
+func (d *Downloader) GetDownloadPath(slug, format string) string {
 	// Sanitize filename
-	filename := sanitizeFilename(talkTitle)
+	filename := sanitizeFilename(slug)
+	if d.layout == LayoutFlat {
+		return filepath.Join(d.baseDir, filename+"-"+format)
+	}
 	return filepath.Join(d.baseDir, filename, format)
 }
 
-// sanitizeFilename converts a string to a valid filename
+// reservedNames are device names Windows doesn't allow as file names,
+// with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename converts a string to a valid filename on every platform
 func sanitizeFilename(s string) string {
 	// Replace invalid characters with underscore
 	invalid := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
@@ -159,5 +583,22 @@ func sanitizeFilename(s string) string {
 	for _, char := range invalid {
 		result = strings.ReplaceAll(result, char, "_")
 	}
+	result = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, result)
+
+	// Trailing dots and spaces are dropped by NTFS
+	result = strings.TrimRight(result, ". ")
+	if result == "" {
+		return "_"
+	}
+
+	base := strings.SplitN(result, ".", 2)[0]
+	if reservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		result = "_" + result
+	}
 	return result
 }
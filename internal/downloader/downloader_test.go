@@ -1,13 +1,22 @@
 package downloader
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/baiyutang/tedfetch/internal/parser"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -59,6 +68,19 @@ func TestDownloader(t *testing.T) {
 		assert.Equal(t, []byte("test content"), content)
 	})
 
+	// Test audio download
+	t.Run("DownloadAudio", func(t *testing.T) {
+		filename := d.GetDownloadPath("test_talk", "audio.mp3")
+		source, err := d.DownloadAudio(&parser.Talk{AudioURL: server.URL}, filename)
+		assert.NoError(t, err)
+		assert.Equal(t, AudioFromTED, source)
+
+		// Verify file exists and has correct content
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("test content"), content)
+	})
+
 	// Test filename sanitization
 	t.Run("GetDownloadPath", func(t *testing.T) {
 		path := d.GetDownloadPath("test/talk:with*invalid?chars", "video.mp4")
@@ -108,3 +130,580 @@ func TestGetDownloadPath(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDownloadPath_Flat(t *testing.T) {
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.SetLayout(LayoutFlat)
+
+	// The slug keeps the files of different talks apart
+	assert.Equal(t, filepath.Join(tempDir, "talk_one-720p.mp4"), d.GetDownloadPath("talk_one", "720p.mp4"))
+	assert.Equal(t, filepath.Join(tempDir, "talk_two-720p.mp4"), d.GetDownloadPath("talk_two", "720p.mp4"))
+
+	path, err := d.TalkPath(NameFields{Slug: "talk_one", Lang: "en"}, "en.srt")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, "talk_one-en.srt"), path)
+
+	// Subdirectories keep the layout
+	sub, err := d.Subdir("playlist")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, "playlist", "talk_one-720p.mp4"), sub.GetDownloadPath("talk_one", "720p.mp4"))
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain title", in: "The power of vulnerability", want: "The power of vulnerability"},
+		{name: "invalid characters", in: `a/b\c:d*e?f"g<h>i|j`, want: "a_b_c_d_e_f_g_h_i_j"},
+		{name: "control characters", in: "line\nbreak\ttab", want: "line_break_tab"},
+		{name: "only dots", in: "...", want: "_"},
+		{name: "empty", in: "", want: "_"},
+		{name: "trailing dots and spaces", in: "Why we sleep. . ", want: "Why we sleep"},
+		{name: "leading dot kept", in: ".hidden", want: ".hidden"},
+		{name: "parent directory", in: "..", want: "_"},
+		{name: "reserved name", in: "CON", want: "_CON"},
+		{name: "reserved name lowercase", in: "nul", want: "_nul"},
+		{name: "reserved name with extension", in: "aux.txt", want: "_aux.txt"},
+		{name: "reserved numbered device", in: "COM1", want: "_COM1"},
+		{name: "reserved numbered device", in: "LPT9.mp4", want: "_LPT9.mp4"},
+		{name: "not reserved", in: "CONSOLE", want: "CONSOLE"},
+		{name: "not reserved COM0", in: "COM0", want: "COM0"},
+		{name: "unicode", in: "Brené Brown: 脆弱的力量", want: "Brené Brown_ 脆弱的力量"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadVideo_ResumesWithRange(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// Announce the full length but drop the connection halfway
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:10])
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)-10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(content[10:])
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, []string{"", "bytes=10-"}, ranges)
+
+	got, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+}
+
+func TestDownloadVideo_RestartsWithoutRangeSupport(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if len(ranges) == 1 {
+			_, _ = w.Write(content[:10])
+			return
+		}
+		// Ignore the Range header and send everything again
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, []string{"", "bytes=10-"}, ranges)
+
+	got, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+}
+
+func TestDownloadVideoContext_Cancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "20")
+		_, _ = w.Write([]byte("0123456789"))
+		w.(http.Flusher).Flush()
+		// Stall until the client goes away
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.SetProgressHandler(func(downloaded, total int64) {
+		if downloaded >= 10 {
+			cancel()
+		}
+	})
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	err = d.DownloadVideoContext(ctx, server.URL, filename)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// The partial download is kept aside for the next run
+	assert.NoFileExists(t, filename)
+	got, err := os.ReadFile(filename + partSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(got))
+}
+
+func TestDownloadVideo_ResumesPartFile(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)-10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(content[10:])
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.SetChecksum(true)
+
+	// A previous run stopped halfway
+	filename := filepath.Join(tempDir, "720p.mp4")
+	assert.NoError(t, os.WriteFile(filename+partSuffix, content[:10], 0644))
+
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, []string{"bytes=10-"}, ranges)
+	assert.NoFileExists(t, filename+partSuffix)
+
+	got, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+	sum := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), d.Checksum(filename))
+}
+
+func TestDownloadVideo_RemovesPartialFileOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Announce the full length but close the connection halfway, every time
+		w.Header().Set("Content-Length", "20")
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	assert.Error(t, d.DownloadVideo(server.URL, filename))
+	assert.NoFileExists(t, filename)
+	assert.NoFileExists(t, filename+partSuffix)
+
+	// Partial files survive when asked to keep them
+	d.SetKeepPartial(true)
+	assert.Error(t, d.DownloadVideo(server.URL, filename))
+	assert.NoFileExists(t, filename)
+	got, err := os.ReadFile(filename + partSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(got))
+}
+
+func TestDownloadVideo_KeepsExistingFileOnBadStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+	d.SetOverwrite(true)
+
+	filename := filepath.Join(tempDir, "720p.mp4")
+	assert.NoError(t, os.WriteFile(filename, []byte("previous download"), 0644))
+	assert.Error(t, d.DownloadVideo(server.URL, filename))
+
+	got, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "previous download", string(got))
+}
+
+func TestContentRange(t *testing.T) {
+	start, total, ok := contentRange("bytes 10-19/20")
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), start)
+	assert.Equal(t, int64(20), total)
+
+	start, total, ok = contentRange("bytes 0-99/*")
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), start)
+	assert.Equal(t, int64(-1), total)
+
+	_, _, ok = contentRange("")
+	assert.False(t, ok)
+}
+
+func TestDownloadVideo_ChecksFullSize(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		// Each response is complete but only covers 5 bytes of the file,
+		// and doesn't tell its size
+		var start int
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, start+4))
+		w.Header().Set("Content-Length", "5")
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(content[start : start+5])
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+	d.SetMaxRetries(4)
+
+	// A previous run stopped after 5 bytes
+	filename := filepath.Join(tempDir, "720p.mp4")
+	assert.NoError(t, os.WriteFile(filename+partSuffix, content[:5], 0644))
+
+	// The file is only finalized once it has the size from the HEAD request
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, []string{"bytes=5-", "bytes=10-", "bytes=15-"}, ranges)
+	got, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+}
+
+func TestDownloadBatch_VerifiesChecksum(t *testing.T) {
+	content := []byte("video content")
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gets, 1)
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	sum := sha256.Sum256(content)
+	talk := &parser.Talk{VideoFormats: []parser.VideoFormat{{Quality: "720p", URL: server.URL, SHA256: hex.EncodeToString(sum[:])}}}
+	filename := filepath.Join(tempDir, "720p.mp4")
+	errs := d.DownloadBatch([]DownloadJob{{URL: server.URL, Filename: filename, Type: JobVideo, Talk: talk}}, 1)
+	assert.NoError(t, errs[0])
+	assert.FileExists(t, filename)
+
+	// A file that doesn't match the talk's checksum is removed, not retried
+	atomic.StoreInt32(&gets, 0)
+	talk.VideoFormats[0].SHA256 = strings.Repeat("0", 64)
+	filename = filepath.Join(tempDir, "corrupt.mp4")
+	errs = d.DownloadBatch([]DownloadJob{{URL: server.URL, Filename: filename, Type: JobVideo, Talk: talk}}, 1)
+	assert.ErrorIs(t, errs[0], ErrChecksumMismatch)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&gets))
+	assert.NoFileExists(t, filename)
+	assert.NoFileExists(t, filename+partSuffix)
+}
+
+func TestDownloadVideo_SkipsCompleteFile(t *testing.T) {
+	content := []byte("test content")
+	var gets, heads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads++
+		} else {
+			gets++
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 1, gets)
+
+	// Same size as the server reports: skipped after a HEAD request
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 1, gets)
+	assert.Equal(t, 1, heads)
+
+	// Different size: downloaded again
+	assert.NoError(t, os.WriteFile(filename, []byte("partial"), 0644))
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 2, gets)
+
+	// Forced: downloaded again without asking
+	d.SetOverwrite(true)
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 3, gets)
+	assert.Equal(t, 2, heads)
+}
+
+func TestSetOverwriteFor(t *testing.T) {
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&gets, 1)
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	video := filepath.Join(tempDir, "talk", "720p.mp4")
+	subtitle := filepath.Join(tempDir, "talk", "en.srt")
+	assert.NoError(t, d.DownloadVideo(server.URL, video))
+	assert.NoError(t, d.DownloadSubtitle(server.URL, subtitle))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&gets))
+
+	// Subtitles are refreshed, complete videos kept even with SetOverwrite
+	d.SetOverwrite(true)
+	d.SetOverwriteFor(JobVideo, false)
+	d.SetOverwriteFor(JobSubtitle, true)
+	assert.NoError(t, d.DownloadVideo(server.URL, video))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&gets))
+	assert.NoError(t, d.DownloadSubtitle(server.URL, subtitle))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&gets))
+
+	// Other types follow SetOverwrite
+	assert.True(t, d.overwriteFor(JobAudio))
+	sub, err := d.Subdir("playlist")
+	assert.NoError(t, err)
+	assert.False(t, sub.overwriteFor(JobVideo))
+}
+
+func TestSubdir(t *testing.T) {
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.SetOverwrite(true)
+	d.SetRateLimit(1024)
+
+	sub, err := d.Subdir("Best of: TED?")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(tempDir, "Best of_ TED_", "talk", "720p.mp4"), sub.GetDownloadPath("talk", "720p.mp4"))
+	assert.True(t, sub.overwrite)
+	assert.Same(t, d.limiter, sub.limiter)
+
+	info, err := os.Stat(filepath.Join(tempDir, "Best of_ TED_"))
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestNewWithClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("subtitle"))
+	}))
+	defer server.Close()
+
+	var requests int
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	tempDir := t.TempDir()
+	d, err := NewWithClient(tempDir, client)
+	assert.NoError(t, err)
+	assert.NoError(t, d.DownloadSubtitle(server.URL, filepath.Join(tempDir, "talk", "en.srt")))
+	assert.Equal(t, 1, requests)
+
+	// Subdirectories keep the injected client
+	sub, err := d.Subdir("playlist")
+	assert.NoError(t, err)
+	assert.Same(t, client, sub.client)
+
+	// A nil client falls back to the default one
+	d, err = NewWithClient(tempDir, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, d.client)
+}
+
+func TestSetUserAgent(t *testing.T) {
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Method+" "+r.UserAgent())
+		w.Header().Set("Content-Length", "8")
+		_, _ = w.Write([]byte("subtitle"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	filename := filepath.Join(tempDir, "talk", "en.srt")
+	assert.NoError(t, d.DownloadSubtitle(server.URL, filename))
+	assert.Equal(t, []string{"GET " + DefaultUserAgent}, agents)
+
+	// The completeness check sends the custom User-Agent too
+	agents = nil
+	d.SetUserAgent("tedfetch-test/1.0")
+	assert.NoError(t, d.DownloadSubtitle(server.URL, filename))
+	assert.Equal(t, []string{"HEAD tedfetch-test/1.0"}, agents)
+}
+
+func TestSetMaxRetries(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			calls++
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	for _, tt := range []struct{ retries, want int }{{1, 1}, {5, 5}, {0, 1}} {
+		calls = 0
+		d.SetMaxRetries(tt.retries)
+		assert.Error(t, d.DownloadVideo(server.URL, filepath.Join(tempDir, "720p.mp4")))
+		assert.Equal(t, tt.want, calls, "retries %d", tt.retries)
+	}
+}
+
+func TestRemoteSize(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", "629145600")
+	}))
+	defer server.Close()
+
+	d, err := New(t.TempDir())
+	assert.NoError(t, err)
+
+	size, err := d.RemoteSize(server.URL + "/video.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(629145600), size)
+	assert.Equal(t, []string{http.MethodHead}, methods)
+
+	// A 404 isn't retried
+	methods = nil
+	_, err = d.RemoteSize(server.URL + "/missing")
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.Code)
+	assert.Equal(t, []string{http.MethodHead}, methods)
+}
+
+func TestRemoteSize_Retries(t *testing.T) {
+	var heads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&heads, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Length", "1000")
+	}))
+	defer server.Close()
+
+	d, err := New(t.TempDir())
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	size, err := d.RemoteSize(server.URL + "/video.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), size)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&heads))
+
+	// Every attempt failing returns the last status
+	atomic.StoreInt32(&heads, -10)
+	_, err = d.RemoteSize(server.URL + "/video.mp4")
+	assert.EqualError(t, err, "bad status: 503 Service Unavailable")
+	assert.Equal(t, int32(-7), atomic.LoadInt32(&heads))
+}
+
+func TestFillVideoSizes(t *testing.T) {
+	var heads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&heads, 1)
+		if r.URL.Path == "/missing.mp4" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", "1000")
+	}))
+	defer server.Close()
+
+	d, err := New(t.TempDir())
+	assert.NoError(t, err)
+	talk := &parser.Talk{VideoFormats: []parser.VideoFormat{
+		{Quality: "360p", URL: server.URL + "/low.mp4"},
+		{Quality: "720p", URL: server.URL + "/missing.mp4"},
+		{Quality: "1080p", URL: server.URL + "/high.mp4", Size: 3000},
+	}}
+	d.FillVideoSizes(talk)
+	// Known sizes are kept without a request, unknown ones stay 0
+	assert.Equal(t, []parser.VideoFormat{
+		{Quality: "360p", URL: server.URL + "/low.mp4", Size: 1000},
+		{Quality: "720p", URL: server.URL + "/missing.mp4"},
+		{Quality: "1080p", URL: server.URL + "/high.mp4", Size: 3000},
+	}, talk.VideoFormats)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&heads))
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
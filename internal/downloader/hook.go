@@ -0,0 +1,21 @@
+package downloader
+
+import "github.com/baiyutang/tedfetch/internal/parser"
+
+// OnComplete registers fn to be called with the path of every file that
+// finished downloading, e.g. to transcode it or copy it elsewhere. talk is
+// the DownloadJob's Talk, or nil for files downloaded without a job such as
+// with DownloadVideo. Files kept because they were already complete count
+// as finished. With DownloadBatch, fn may be called from several goroutines
+// at once. A nil fn removes the hook.
+func (d *Downloader) OnComplete(fn func(path string, talk *parser.Talk)) {
+	d.onComplete = fn
+}
+
+// completed calls the OnComplete hook for path when err is nil and returns err
+func (d *Downloader) completed(err error, path string, talk *parser.Talk) error {
+	if err == nil && d.onComplete != nil {
+		d.onComplete(path, talk)
+	}
+	return err
+}
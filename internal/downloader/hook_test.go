@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baiyutang/tedfetch/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+	d.SetProgressHandler(func(downloaded, total int64) {})
+
+	var mu sync.Mutex
+	var paths []string
+	talks := make(map[string]*parser.Talk)
+	d.OnComplete(func(path string, talk *parser.Talk) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, path)
+		talks[path] = talk
+	})
+
+	talk := &parser.Talk{Slug: "talk"}
+	video := filepath.Join(tempDir, "talk", "720p.mp4")
+	subtitle := filepath.Join(tempDir, "talk", "en.srt")
+	errs := d.DownloadBatch([]DownloadJob{
+		{URL: server.URL + "/video", Filename: video, Type: JobVideo, Talk: talk},
+		{Content: []byte("1\n"), Filename: subtitle, Type: JobSubtitle, Talk: talk},
+		{URL: server.URL + "/missing", Filename: filepath.Join(tempDir, "talk", "fr.srt"), Type: JobSubtitle, Talk: talk},
+	}, 2)
+	assert.Error(t, errs[2])
+
+	// Failed downloads are not reported
+	sort.Strings(paths)
+	assert.Equal(t, []string{video, subtitle}, paths)
+	assert.Same(t, talk, talks[video])
+	assert.Same(t, talk, talks[subtitle])
+
+	// Files downloaded without a job have no talk, and Subdir keeps the hook
+	sub, err := d.Subdir("other")
+	assert.NoError(t, err)
+	other := filepath.Join(tempDir, "other", "720p.mp4")
+	assert.NoError(t, sub.DownloadVideo(server.URL+"/video", other))
+	assert.Contains(t, paths, other)
+	assert.Nil(t, talks[other])
+}
@@ -0,0 +1,18 @@
+package downloader
+
+import (
+	"log/slog"
+
+	"github.com/baiyutang/tedfetch/internal/logging"
+)
+
+// SetLogger sets the logger receiving the downloader's diagnostics, such as
+// skipped files and cleanup errors; nil discards them again
+func (d *Downloader) SetLogger(logger *slog.Logger) {
+	d.logger = logger
+}
+
+// log returns the logger set with SetLogger, or one discarding everything
+func (d *Downloader) log() *slog.Logger {
+	return logging.Or(d.logger)
+}
@@ -0,0 +1,117 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// spinnerOutput is where spinners are drawn; replaced in tests
+var spinnerOutput io.Writer = os.Stderr
+
+// progressFor returns the progress factory for a single download: the
+// progress handler when one is set, otherwise a terminal progress bar
+func (d *Downloader) progressFor(kind JobType) func(offset, length int64) io.Writer {
+	if d.progress == nil {
+		return newProgressBar(kind)
+	}
+	return func(offset, length int64) io.Writer {
+		total := int64(-1)
+		if length >= 0 {
+			total = offset + length
+		}
+		return &progressWriter{handler: d.progress, downloaded: offset, total: total}
+	}
+}
+
+// newProgressBar returns a progress factory rendering one bar per attempt,
+// or a spinner with the byte count when the server sends no Content-Length
+func newProgressBar(kind JobType) func(offset, length int64) io.Writer {
+	return func(offset, length int64) io.Writer {
+		description := fmt.Sprintf("Downloading %s", kind)
+		if length < 0 {
+			return newSpinner(description)
+		}
+		return progressbar.DefaultBytes(length, description)
+	}
+}
+
+// newSpinner returns an indeterminate progress indicator for a download of
+// unknown length, e.g. a chunked response
+func newSpinner(description string) *progressbar.ProgressBar {
+	return progressbar.NewOptions64(-1,
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetWriter(spinnerOutput),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprint(spinnerOutput, "\n")
+		}),
+		progressbar.OptionSetRenderBlankState(true),
+	)
+}
+
+// progressWriter reports the running byte count to a progress handler
+type progressWriter struct {
+	handler    func(downloaded, total int64)
+	downloaded int64
+	total      int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.downloaded += int64(len(p))
+	w.handler(w.downloaded, w.total)
+	return len(p), nil
+}
+
+// aggregateProgress counts the bytes of parallel downloads in one bar, or
+// reports their sum to a progress handler when bar is nil
+type aggregateProgress struct {
+	mu         sync.Mutex
+	bar        *progressbar.ProgressBar
+	handler    func(downloaded, total int64)
+	downloaded int64
+}
+
+// add counts n more bytes, or takes back -n bytes
+func (a *aggregateProgress) add(n int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.bar != nil {
+		_ = a.bar.Add64(n)
+		return
+	}
+	a.downloaded += n
+	a.handler(a.downloaded, -1)
+}
+
+// forJob returns the progress factory of one job. When an attempt starts
+// below the bytes already counted for the job, e.g. over again because the
+// server ignored the Range request, the bytes that are fetched again are
+// taken back first so the total matches what the files hold.
+func (a *aggregateProgress) forJob() func(offset, length int64) io.Writer {
+	var counted int64
+	return func(offset, length int64) io.Writer {
+		if counted > offset {
+			a.add(offset - counted)
+			counted = offset
+		}
+		return writerFunc(func(p []byte) (int, error) {
+			counted += int64(len(p))
+			a.add(int64(len(p)))
+			return len(p), nil
+		})
+	}
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
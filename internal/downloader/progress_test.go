@@ -0,0 +1,184 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetProgressHandler(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	var last, total int64
+	d.SetProgressHandler(func(downloaded, n int64) {
+		assert.GreaterOrEqual(t, downloaded, last)
+		last, total = downloaded, n
+	})
+
+	assert.NoError(t, d.DownloadVideo(server.URL, filepath.Join(tempDir, "talk", "720p.mp4")))
+	assert.Equal(t, int64(len(content)), last)
+	assert.Equal(t, int64(len(content)), total)
+}
+
+func TestSetProgressHandler_CountsResumedBytes(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:10])
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)-10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(content[10:])
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.retryDelay = time.Millisecond
+
+	type update struct{ downloaded, total int64 }
+	var updates []update
+	d.SetProgressHandler(func(downloaded, total int64) {
+		updates = append(updates, update{downloaded, total})
+	})
+
+	assert.NoError(t, d.DownloadVideo(server.URL, filepath.Join(tempDir, "talk", "720p.mp4")))
+	assert.NotEmpty(t, updates)
+	// The resumed attempt reports whole-file numbers
+	assert.Equal(t, update{int64(len(content)), int64(len(content))}, updates[len(updates)-1])
+}
+
+func TestSetProgressHandler_Batch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	var last int64
+	d.SetProgressHandler(func(downloaded, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = downloaded
+		assert.Equal(t, int64(-1), total)
+	})
+
+	jobs := make([]DownloadJob, 3)
+	for i := range jobs {
+		jobs[i] = DownloadJob{URL: server.URL, Filename: filepath.Join(tempDir, strconv.Itoa(i)+".srt"), Type: JobSubtitle}
+	}
+	for _, err := range d.DownloadBatch(jobs, 3) {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(3*len("content")), last)
+}
+
+func TestSetProgressHandler_BatchRetry(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if n == 1 {
+			// Drop the connection halfway
+			_, _ = w.Write(content[:10])
+			return
+		}
+		// Ignore the Range request and send everything again
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	d.sleep = (&fakeClock{}).sleep
+
+	var last int64
+	d.SetProgressHandler(func(downloaded, total int64) { last = downloaded })
+
+	jobs := []DownloadJob{
+		{URL: server.URL + "/a", Filename: filepath.Join(tempDir, "a.mp4"), Type: JobVideo},
+		{URL: server.URL + "/b", Filename: filepath.Join(tempDir, "b.mp4"), Type: JobVideo},
+	}
+	for _, err := range d.DownloadBatch(jobs, 2) {
+		assert.NoError(t, err)
+	}
+	// The bytes of the failed attempts are not counted twice
+	assert.Equal(t, int64(2*len(content)), last)
+}
+
+func TestDownloadVideo_UnknownLength(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var gets int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets++
+			_, _ = w.Write(content[:10])
+		}
+		// Flushing before the end makes the response chunked, without a length
+		w.(http.Flusher).Flush()
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content[10:])
+		}
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	var last, total int64
+	d.SetProgressHandler(func(downloaded, n int64) { last, total = downloaded, n })
+
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, int64(len(content)), last)
+	assert.Equal(t, int64(-1), total)
+
+	// The size of the existing file can't be compared, so it is fetched again
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 2, gets)
+
+	// The terminal shows a spinner with the byte count instead of a bar
+	var output bytes.Buffer
+	saved := spinnerOutput
+	spinnerOutput = &output
+	defer func() { spinnerOutput = saved }()
+	d.SetProgressHandler(nil)
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.Equal(t, 3, gets)
+	// A spinner frame and no percentage, since the total is unknown
+	assert.Contains(t, output.String(), "⠋ Downloading video")
+	assert.NotContains(t, output.String(), "%")
+}
@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every download of a Downloader
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{
+		rate: float64(bytesPerSec),
+		last: time.Now(),
+	}
+}
+
+// wait blocks until n bytes may pass. Tokens can go negative, which
+// reserves future capacity so concurrent readers queue up fairly.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// chunkSize bounds a single read so throttled progress stays smooth
+func (l *rateLimiter) chunkSize() int {
+	size := int(l.rate / 10)
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// throttledReader limits the speed at which r can be read
+type throttledReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if max := t.limiter.chunkSize(); len(p) > max {
+		p = p[:max]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.limiter.wait(n)
+	}
+	return n, err
+}
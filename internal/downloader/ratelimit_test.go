@@ -0,0 +1,53 @@
+package downloader
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottledReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 5000)
+	r := &throttledReader{r: bytes.NewReader(data), limiter: newRateLimiter(20000)}
+
+	start := time.Now()
+	got, err := io.ReadAll(r)
+	elapsed := time.Since(start)
+
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	// 5000 bytes at 20000 B/s take roughly 250ms
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+}
+
+func TestSetRateLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 4000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+
+	d.SetRateLimit(0)
+	assert.Nil(t, d.limiter)
+
+	d.SetRateLimit(20000)
+	filename := filepath.Join(tempDir, "talk", "720p.mp4")
+	start := time.Now()
+	assert.NoError(t, d.DownloadVideo(server.URL, filename))
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	got, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+}
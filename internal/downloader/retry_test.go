@@ -0,0 +1,86 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock records the waits between retries instead of sleeping
+type fakeClock struct {
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	return ctx.Err()
+}
+
+func TestDownload_BacksOffBetweenRetries(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			return
+		}
+		calls++
+		if calls <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("video data"))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	clock := &fakeClock{}
+	d.sleep = clock.sleep
+	d.retryDelay = time.Second
+
+	assert.NoError(t, d.DownloadVideo(server.URL, filepath.Join(tempDir, "720p.mp4")))
+	assert.Equal(t, 3, calls)
+
+	// Exponential backoff with up to 50% jitter
+	if assert.Len(t, clock.sleeps, 2) {
+		assert.GreaterOrEqual(t, clock.sleeps[0], time.Second)
+		assert.LessOrEqual(t, clock.sleeps[0], 1500*time.Millisecond)
+		assert.GreaterOrEqual(t, clock.sleeps[1], 2*time.Second)
+		assert.LessOrEqual(t, clock.sleeps[1], 3*time.Second)
+	}
+}
+
+func TestDownload_HonorsRetryAfter(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			return
+		}
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte("video data"))
+		}
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	clock := &fakeClock{}
+	d.sleep = clock.sleep
+
+	assert.NoError(t, d.DownloadVideo(server.URL, filepath.Join(tempDir, "720p.mp4")))
+	assert.Equal(t, []time.Duration{7 * time.Second, 0}, clock.sleeps)
+}
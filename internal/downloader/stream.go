@@ -0,0 +1,178 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/baiyutang/tedfetch/internal/logging"
+	"github.com/baiyutang/tedfetch/internal/retry"
+)
+
+// StreamVideo copies the video at url into w, e.g. the stdin of ffmpeg,
+// without writing a file. Progress is reported like for DownloadVideo.
+// Failed attempts are retried from the bytes w already received: with a
+// Range request, or by skipping them when the server doesn't support it.
+// Errors writing to w are not retried.
+func (d *Downloader) StreamVideo(ctx context.Context, url string, w io.Writer) error {
+	return d.stream(ctx, url, w, 0, JobVideo, d.progressFor(JobVideo))
+}
+
+// resetter is implemented by writers that can drop what they received, so
+// stream can start a download over instead of skipping bytes
+type resetter interface {
+	Reset() error
+}
+
+// stream implements StreamVideo for files of kind, see download for
+// progress. w already holds the first offset bytes of the file. It succeeds
+// once w holds the whole file, whose size comes from the responses or,
+// when they only give a range, from a HEAD request.
+func (d *Downloader) stream(ctx context.Context, url string, w io.Writer, offset int64, kind JobType, progress func(offset, length int64) io.Writer) error {
+	out := &countingWriter{w: w, n: offset}
+	reset, canReset := w.(resetter)
+	// restart empties w when it can be, so the next attempt starts over
+	restart := func() error {
+		if !canReset || out.n == 0 {
+			return nil
+		}
+		if err := reset.Reset(); err != nil {
+			return fmt.Errorf("failed to restart %s: %w", kind, err)
+		}
+		out.n = 0
+		return nil
+	}
+
+	var lastErr error
+	var wait time.Duration
+	hasWait := false // whether the last response asked for wait with Retry-After
+	for attempt := 0; attempt < d.maxRetries; attempt++ {
+		if attempt > 0 {
+			// Back off so a failing server isn't hit in a tight loop
+			delay := retry.Delay(d.retryDelay, attempt-1)
+			if hasWait {
+				delay, hasWait = wait, false
+			}
+			if err := d.sleep(ctx, delay); err != nil {
+				return d.cancelled(ctx)
+			}
+		}
+		if ctx.Err() != nil {
+			return d.cancelled(ctx)
+		}
+
+		offset := out.n
+		req, err := d.newRequest(ctx, url, offset)
+		if err != nil {
+			return err
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return d.cancelled(ctx)
+			}
+			lastErr = fmt.Errorf("failed to get %s: %w", kind, err)
+			continue
+		}
+
+		// skip counts the bytes of the response w already received
+		var skip int64
+		total := resp.ContentLength // size of the whole file, -1 if unknown
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+			start, size, ok := contentRange(resp.Header.Get("Content-Range"))
+			if !ok || start != offset {
+				logging.CloseBody(d.log(), resp.Body)
+				lastErr = fmt.Errorf("unexpected content range: %q", resp.Header.Get("Content-Range"))
+				if err := restart(); err != nil {
+					return err
+				}
+				continue
+			}
+			total = size
+			if total < 0 {
+				// "bytes start-end/*": ask for the size of the whole file
+				if size, err := d.RemoteSizeContext(ctx, url); err == nil {
+					total = size
+				}
+			}
+		case resp.StatusCode == http.StatusOK:
+			// The whole file again: start over, or skip what w has
+			if err := restart(); err != nil {
+				logging.CloseBody(d.log(), resp.Body)
+				return err
+			}
+			offset, skip = out.n, out.n
+		default:
+			logging.CloseBody(d.log(), resp.Body)
+			if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+				if err := restart(); err != nil {
+					return err
+				}
+			}
+			wait, hasWait = retry.After(resp)
+			lastErr = fmt.Errorf("bad status: %s", resp.Status)
+			continue
+		}
+
+		body := d.body(ctx, resp.Body)
+		if _, err := io.CopyN(io.Discard, body, skip); err != nil {
+			logging.CloseBody(d.log(), resp.Body)
+			if ctx.Err() != nil {
+				return d.cancelled(ctx)
+			}
+			lastErr = fmt.Errorf("failed to download %s: %w", kind, err)
+			continue
+		}
+
+		length := resp.ContentLength
+		if length >= 0 {
+			length -= skip
+			if total < 0 {
+				total = offset + length
+			}
+		}
+		_, err = io.Copy(io.MultiWriter(out, progress(offset, length)), body)
+		logging.CloseBody(d.log(), resp.Body)
+		if out.err != nil {
+			return fmt.Errorf("failed to write %s: %w", kind, out.err)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return d.cancelled(ctx)
+			}
+			lastErr = fmt.Errorf("failed to download %s: %w", kind, err)
+			continue
+		}
+		if total >= 0 && out.n != total {
+			lastErr = fmt.Errorf("failed to download %s: got %d bytes, want %d", kind, out.n, total)
+			if out.n > total {
+				if err := restart(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// countingWriter counts the bytes written to w and remembers its error, so
+// that it can be told apart from a read error of the download
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
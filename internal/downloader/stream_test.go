@@ -0,0 +1,117 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamVideo(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	d, err := New(tempDir)
+	assert.NoError(t, err)
+	var downloaded, total int64
+	d.SetProgressHandler(func(n, length int64) { downloaded, total = n, length })
+
+	var buf bytes.Buffer
+	assert.NoError(t, d.StreamVideo(context.Background(), server.URL, &buf))
+	assert.Equal(t, content, buf.Bytes())
+	assert.Equal(t, int64(len(content)), downloaded)
+	assert.Equal(t, int64(len(content)), total)
+
+	// Nothing is written to disk
+	entries, err := os.ReadDir(tempDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestStreamVideo_Resumes(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	tests := []struct {
+		name          string
+		supportsRange bool
+	}{
+		{"with Range", true},
+		{"without Range support", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if len(ranges) == 1 {
+					// Announce the full length but drop the connection halfway
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					_, _ = w.Write(content[:10])
+					return
+				}
+				if !tt.supportsRange {
+					_, _ = w.Write(content)
+					return
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 10-%d/%d", len(content)-1, len(content)))
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)-10))
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(content[10:])
+			}))
+			defer server.Close()
+
+			d, err := New(t.TempDir())
+			assert.NoError(t, err)
+			d.SetProgressHandler(func(downloaded, total int64) {})
+			d.sleep = (&fakeClock{}).sleep
+
+			var buf bytes.Buffer
+			assert.NoError(t, d.StreamVideo(context.Background(), server.URL, &buf))
+			assert.Equal(t, []string{"", "bytes=10-"}, ranges)
+			assert.Equal(t, content, buf.Bytes())
+		})
+	}
+}
+
+// failingWriter accepts n bytes, then fails
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		written := w.n
+		w.n = 0
+		return written, errors.New("broken pipe")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestStreamVideo_WriteError(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	d, err := New(t.TempDir())
+	assert.NoError(t, err)
+	d.SetProgressHandler(func(downloaded, total int64) {})
+
+	// A reader that went away is not retried
+	err = d.StreamVideo(context.Background(), server.URL, &failingWriter{n: 4})
+	assert.ErrorContains(t, err, "failed to write video: broken pipe")
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,126 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// NameFields holds the talk details a name template can refer to
+type NameFields struct {
+	Title   string
+	Speaker string
+	Slug    string
+	Quality string // Video quality, or "audio" for the audio track
+	Lang    string // Subtitle language code
+	Date    string // Published date, YYYY-MM-DD
+}
+
+// Layout chooses where downloads go when no name template is set
+type Layout int
+
+const (
+	// LayoutNested saves each talk's files in a folder named after its
+	// slug, inside a folder named after its speaker, e.g.
+	// <speaker>/<slug>/720p.mp4
+	LayoutNested Layout = iota
+	// LayoutFlat saves every file in the base directory, prefixed with the
+	// slug so talks can't collide, e.g. <slug>-720p.mp4
+	LayoutFlat
+)
+
+// SetLayout chooses between the nested and the flat layout
+func (d *Downloader) SetLayout(layout Layout) {
+	d.layout = layout
+}
+
+// SetNameTemplate lays out downloads with a text/template such as
+// "{{.Speaker}}/{{.Title}}-{{.Quality}}", expanded with NameFields.
+// Slashes separate directories, each segment is sanitized and the file
+// extension is appended. An empty template restores the default
+// <speaker>/<slug>/<format> layout.
+func (d *Downloader) SetNameTemplate(tmpl string) error {
+	if tmpl == "" {
+		d.nameTemplate = nil
+		return nil
+	}
+
+	t, err := template.New("name").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("invalid name template: %w", err)
+	}
+	// Catch references to unknown fields up front
+	if err := t.Execute(&bytes.Buffer{}, NameFields{}); err != nil {
+		return fmt.Errorf("invalid name template: %w", err)
+	}
+	d.nameTemplate = t
+	return nil
+}
+
+// TalkPath returns where to save a file of a talk. format is the file name
+// of the default layout, e.g. "720p.mp4" or "en.srt", which is saved as
+// <speaker>/<slug>/<format>, or <slug>/<format> for a talk without a
+// speaker. With a name template only the extension of format is kept, and
+// subtitles get a ".<lang>" suffix unless the template uses Lang.
+func (d *Downloader) TalkPath(fields NameFields, format string) (string, error) {
+	if d.nameTemplate == nil {
+		if d.layout == LayoutNested && fields.Speaker != "" {
+			// Talks are grouped by speaker, so talks with the same title by
+			// different speakers get separate folders
+			return filepath.Join(d.baseDir, sanitizeFilename(fields.Speaker), sanitizeFilename(fields.Slug), format), nil
+		}
+		return d.GetDownloadPath(fields.Slug, format), nil
+	}
+
+	name, err := d.expandName(fields)
+	if err != nil {
+		return "", err
+	}
+	if fields.Lang != "" {
+		withoutLang := fields
+		withoutLang.Lang = ""
+		if plain, err := d.expandName(withoutLang); err == nil && plain == name {
+			name += "." + sanitizeFilename(fields.Lang)
+		}
+	}
+	return filepath.Join(d.baseDir, name+filepath.Ext(format)), nil
+}
+
+// expandName executes the name template and sanitizes every path segment.
+// Field values are sanitized first so a "/" in a title can't add a directory.
+func (d *Downloader) expandName(fields NameFields) (string, error) {
+	clean := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return sanitizeFilename(s)
+	}
+	fields = NameFields{
+		Title:   clean(fields.Title),
+		Speaker: clean(fields.Speaker),
+		Slug:    clean(fields.Slug),
+		Quality: clean(fields.Quality),
+		Lang:    clean(fields.Lang),
+		Date:    clean(fields.Date),
+	}
+
+	var buf bytes.Buffer
+	if err := d.nameTemplate.Execute(&buf, fields); err != nil {
+		return "", fmt.Errorf("failed to expand name template: %w", err)
+	}
+
+	var segments []string
+	for _, segment := range strings.FieldsFunc(buf.String(), func(r rune) bool { return r == '/' || r == '\\' }) {
+		segment = strings.TrimSpace(segment)
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		segments = append(segments, sanitizeFilename(segment))
+	}
+	if len(segments) == 0 {
+		return "", fmt.Errorf("name template produced an empty file name")
+	}
+	return filepath.Join(segments...), nil
+}
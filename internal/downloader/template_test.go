@@ -0,0 +1,100 @@
+package downloader
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTalkPath(t *testing.T) {
+	fields := NameFields{
+		Title:   "AC/DC: Live?",
+		Speaker: "Brené Brown",
+		Slug:    "brene_brown_the_power_of_vulnerability",
+		Quality: "720p",
+		Date:    "2011-01-03",
+	}
+	subtitle := fields
+	subtitle.Quality = ""
+	subtitle.Lang = "zh-cn"
+	noSpeaker := fields
+	noSpeaker.Speaker = ""
+
+	tests := []struct {
+		name   string
+		tmpl   string
+		fields NameFields
+		format string
+		want   string
+	}{
+		{
+			name:   "default layout",
+			fields: fields,
+			format: "720p.mp4",
+			want:   filepath.Join("downloads", "Brené Brown", "brene_brown_the_power_of_vulnerability", "720p.mp4"),
+		},
+		{
+			name:   "default layout without speaker",
+			fields: noSpeaker,
+			format: "720p.mp4",
+			want:   filepath.Join("downloads", "brene_brown_the_power_of_vulnerability", "720p.mp4"),
+		},
+		{
+			name:   "speaker directory",
+			tmpl:   "{{.Speaker}}/{{.Title}}-{{.Quality}}",
+			fields: fields,
+			format: "720p.mp4",
+			want:   filepath.Join("downloads", "Brené Brown", "AC_DC_ Live_-720p.mp4"),
+		},
+		{
+			name:   "date prefix",
+			tmpl:   "{{.Date}} {{.Slug}}",
+			fields: fields,
+			format: "metadata.json",
+			want:   filepath.Join("downloads", "2011-01-03 brene_brown_the_power_of_vulnerability.json"),
+		},
+		{
+			name:   "subtitle gets language suffix",
+			tmpl:   "{{.Speaker}}/{{.Slug}}",
+			fields: subtitle,
+			format: "zh-cn.srt",
+			want:   filepath.Join("downloads", "Brené Brown", "brene_brown_the_power_of_vulnerability.zh-cn.srt"),
+		},
+		{
+			name:   "template using language",
+			tmpl:   "{{.Slug}}/{{.Lang}}",
+			fields: subtitle,
+			format: "zh-cn.srt",
+			want:   filepath.Join("downloads", "brene_brown_the_power_of_vulnerability", "zh-cn.srt"),
+		},
+		{
+			name:   "parent directories are dropped",
+			tmpl:   "../{{.Slug}}",
+			fields: fields,
+			format: "720p.mp4",
+			want:   filepath.Join("downloads", "brene_brown_the_power_of_vulnerability.mp4"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Downloader{baseDir: "downloads"}
+			assert.NoError(t, d.SetNameTemplate(tt.tmpl))
+
+			got, err := d.TalkPath(tt.fields, tt.format)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetNameTemplate_Invalid(t *testing.T) {
+	d := &Downloader{baseDir: "downloads"}
+	assert.Error(t, d.SetNameTemplate("{{.Title"))
+	assert.Error(t, d.SetNameTemplate("{{.Views}}"))
+
+	assert.NoError(t, d.SetNameTemplate("{{.Lang}}"))
+	_, err := d.TalkPath(NameFields{Slug: "talk"}, "720p.mp4")
+	assert.Error(t, err, "empty expansion")
+}
@@ -0,0 +1,29 @@
+// Package logging holds the slog helpers shared by the parser and the downloader
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Discard drops every record; the default when no logger is set
+var Discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Debug writes debug records to stderr
+var Debug = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+// Or returns logger, or Discard when it is nil
+func Or(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return Discard
+	}
+	return logger
+}
+
+// CloseBody closes a response body, logging any error to logger
+func CloseBody(logger *slog.Logger, body io.Closer) {
+	if err := body.Close(); err != nil {
+		logger.Warn("failed to close response body", "error", err)
+	}
+}
@@ -0,0 +1,24 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingCloser struct{}
+
+func (failingCloser) Close() error { return errors.New("boom") }
+
+func TestCloseBody(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	CloseBody(logger, failingCloser{})
+	assert.Contains(t, buf.String(), `msg="failed to close response body" error=boom`)
+
+	assert.Same(t, Discard, Or(nil))
+	assert.Same(t, logger, Or(logger))
+}
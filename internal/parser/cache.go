@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache stores raw GraphQL and HTML responses so repeated parses of the same
+// talk don't hit TED again. Keys look like "graphql_<slug>" or "html_<slug>".
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, data []byte) error
+}
+
+// FileCache is a Cache keeping one file per entry in a directory
+type FileCache struct {
+	Dir string
+	// TTL is how long entries stay valid; 0 means they never expire
+	TTL time.Duration
+}
+
+// NewFileCache creates dir if needed and returns a FileCache using it
+func NewFileCache(dir string, ttl time.Duration) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &FileCache{Dir: dir, TTL: ttl}, nil
+}
+
+// Get returns the entry for key unless it is missing or expired
+func (c *FileCache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if c.TTL > 0 && time.Since(info.ModTime()) > c.TTL {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set stores data under key, replacing any previous entry
+func (c *FileCache) Set(key string, data []byte) error {
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := os.CreateTemp(c.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+	return nil
+}
+
+// path maps key to a file name, replacing characters that are unsafe in paths
+func (c *FileCache) path(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+	return filepath.Join(c.Dir, name+".cache")
+}
+
+// cacheGet returns the cached response for key, if the parser has a cache
+func (p *Parser) cacheGet(key string) ([]byte, bool) {
+	if p.Cache == nil {
+		return nil, false
+	}
+	data, ok := p.Cache.Get(key)
+	if ok {
+		p.debugPrint("Using cached response: %s", key)
+	}
+	return data, ok
+}
+
+// cacheSet stores a successful response, if the parser has a cache
+func (p *Parser) cacheSet(key string, data []byte) {
+	if p.Cache == nil {
+		return
+	}
+	if err := p.Cache.Set(key, data); err != nil {
+		p.debugPrint("Failed to cache %s: %v", key, err)
+	}
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCache(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+
+	_, ok := cache.Get("graphql_test_slug")
+	assert.False(t, ok)
+
+	assert.NoError(t, cache.Set("graphql_test_slug", []byte(`{"data":{}}`)))
+	data, ok := cache.Get("graphql_test_slug")
+	assert.True(t, ok)
+	assert.Equal(t, `{"data":{}}`, string(data))
+
+	// Keys with path separators stay inside the cache directory
+	assert.NoError(t, cache.Set("html_../escape", []byte("<html></html>")))
+	data, ok = cache.Get("html_../escape")
+	assert.True(t, ok)
+	assert.Equal(t, "<html></html>", string(data))
+
+	// Expired entries are ignored
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(cache.path("graphql_test_slug"), old, old))
+	_, ok = cache.Get("graphql_test_slug")
+	assert.False(t, ok)
+
+	cache.TTL = 0
+	_, ok = cache.Get("graphql_test_slug")
+	assert.True(t, ok, "entries never expire without a TTL")
+}
+
+func TestParseURL_UsesCache(t *testing.T) {
+	mockServer := newMockTEDServer(lowVideoGraphQL, `<html><h1>Test Title</h1><h2>Test Speaker</h2></html>`)
+	defer mockServer.Close()
+
+	var requests []string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			requests = append(requests, r.URL.Path)
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	cache, err := NewFileCache(t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+
+	p := NewWithClient(client)
+	p.GraphqlURL = mockServer.URL + "/graphql"
+	p.Cache = cache
+
+	first, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/graphql", "/graphql", "/talks/test_slug"}, requests)
+
+	// The second parse is served entirely from the cache
+	second, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Len(t, requests, 3)
+	assert.Equal(t, first, second)
+}
+
+func TestParseURL_DoesNotCacheErrors(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+
+	mockServer := newMockTEDServer([]byte(`{"errors": [{"message": "boom"}]}`), `<html></html>`)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+	p.Cache = cache
+
+	_, err = p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.Error(t, err)
+
+	_, ok := cache.Get("graphql_test_slug")
+	assert.False(t, ok)
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding is sent with every request. Setting it by hand turns off the
+// transport's own gzip handling, so decodeBody decompresses responses for
+// custom transports too.
+const acceptEncoding = "gzip, deflate"
+
+// decodeBody replaces the body of a gzip or deflate encoded response with
+// its decompressed content
+func decodeBody(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return nil
+	}
+
+	var reader io.ReadCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(resp.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(resp.Body)
+	default:
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if errors.Is(err, io.EOF) {
+		// Empty body, nothing to decode
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", encoding, err)
+	}
+
+	resp.Body = &decodedBody{Reader: reader, decoder: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody reads the decompressed content of a response body and closes both
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	derr := b.decoder.Close()
+	if err := b.body.Close(); err != nil {
+		return err
+	}
+	return derr
+}
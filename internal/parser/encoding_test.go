@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compress encodes data with the given Content-Encoding
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == "gzip" {
+		w = gzip.NewWriter(&buf)
+	} else {
+		w = zlib.NewWriter(&buf)
+	}
+	_, err := w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseURL_CompressedResponses(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "talk_page.html"))
+	assert.NoError(t, err)
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			var accepted []string
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				accepted = append(accepted, r.Header.Get("Accept-Encoding"))
+				body := fixture
+				if r.URL.Path == "/graphql" {
+					body = lowVideoGraphQL
+				}
+				if strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+					w.Header().Set("Content-Encoding", encoding)
+					body = compress(t, encoding, body)
+				}
+				_, _ = w.Write(body)
+			}))
+			defer mockServer.Close()
+
+			p := NewWithClient(mockServer.Client())
+			p.GraphqlURL = mockServer.URL + "/graphql"
+
+			talk, err := p.ParseURL(mockServer.URL + "/talks/brene_brown_the_power_of_vulnerability")
+			assert.NoError(t, err)
+			assert.Equal(t, "The power of vulnerability", talk.Title)
+			assert.Equal(t, "Brené Brown", talk.Speaker)
+			assert.Equal(t, "https://download.ted.com/talks/test-low.mp4", talk.VideoURLs["360p"])
+			assert.Equal(t, []string{acceptEncoding, acceptEncoding, acceptEncoding}, accepted)
+		})
+	}
+}
+
+func TestDecodeBody_Invalid(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": []string{"gzip"}},
+		Body:   io.NopCloser(strings.NewReader("not gzip")),
+	}
+	assert.Error(t, decodeBody(resp))
+
+	resp = &http.Response{
+		Header: http.Header{"Content-Encoding": []string{"br"}},
+		Body:   io.NopCloser(strings.NewReader("")),
+	}
+	assert.Error(t, decodeBody(resp))
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned (wrapped) by the parser; test for them with errors.Is
+var (
+	// ErrInvalidURL is returned when a URL does not point to a TED talk
+	ErrInvalidURL = errors.New("invalid TED talk URL")
+	// ErrTalkNotFound is returned when TED has no talk at the URL or for the title
+	ErrTalkNotFound = errors.New("talk not found")
+	// ErrPageNotFound is returned when TED has no playlist, topic or talk
+	// list at the URL
+	ErrPageNotFound = errors.New("page not found")
+	// ErrNoDownloads is returned when a talk page offers neither videos nor subtitles
+	ErrNoDownloads = errors.New("no video or subtitle data found")
+	// ErrGeoBlocked is returned when TED doesn't serve the talk in the client's region
+	ErrGeoBlocked = errors.New("talk is not available in this region")
+	// ErrConsentWall is returned when TED serves a cookie consent page instead of the talk
+	ErrConsentWall = errors.New("cookie consent page served instead of the talk")
+	// ErrUnexpectedFormat is returned when a talk page carries video data TED
+	// has changed in a way the parser doesn't understand
+	ErrUnexpectedFormat = errors.New("unexpected talk page format")
+	// ErrTranscriptNotFound is returned when a talk has no transcript in the requested language
+	ErrTranscriptNotFound = errors.New("transcript not found")
+	// ErrSubtitleNotFound is returned when a video has no subtitles in the requested language
+	ErrSubtitleNotFound = errors.New("subtitles not found")
+	// ErrInvalidLimit is returned when a list of talks is asked for fewer than one talk
+	ErrInvalidLimit = errors.New("invalid limit")
+	// ErrRateLimited is returned when TED still answers 429 Too Many Requests
+	// after the request has been retried
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// GraphQLError is returned (wrapped) when TED's GraphQL API answers with an
+// error; retrieve it with errors.As
+type GraphQLError struct {
+	Code    string // extensions.code, e.g. "GRAPHQL_VALIDATION_FAILED"; empty if not given
+	Message string
+}
+
+func (e *GraphQLError) Error() string {
+	if e.Code == "" {
+		return "GraphQL error: " + e.Message
+	}
+	return fmt.Sprintf("GraphQL error %s: %s", e.Code, e.Message)
+}
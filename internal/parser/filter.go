@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// TopicFilter narrows down the talks returned by ParseTopicFiltered. The
+// zero value applies no filter.
+type TopicFilter struct {
+	// Sort is "newest", "oldest", "popular" or "relevance"; empty keeps
+	// TED's default order
+	Sort string
+	// MaxDuration drops talks longer than this; 0 means no limit
+	MaxDuration time.Duration
+	// Language keeps talks available in this language, e.g. "es"
+	Language string
+}
+
+// topicSorts are the orders TED's talks list supports
+var topicSorts = map[string]bool{
+	"newest":    true,
+	"oldest":    true,
+	"popular":   true,
+	"relevance": true,
+}
+
+// durationBuckets are the duration[] ranges of TED's talks list, with the
+// shortest talk each one holds
+var durationBuckets = []struct {
+	param string
+	min   time.Duration
+}{
+	{"0-6", 0},
+	{"6-12", 6 * time.Minute},
+	{"12-18", 12 * time.Minute},
+	{"18+", 18 * time.Minute},
+}
+
+// ParseTopicFiltered is like ParseTopic but sorts and filters the talks
+// with filter
+func (p *Parser) ParseTopicFiltered(query string, limit int, filter TopicFilter) ([]Talk, error) {
+	return p.ParseTopicFilteredContext(context.Background(), query, limit, filter)
+}
+
+// ParseTopicFilteredContext is like ParseTopicFiltered but aborts when ctx
+// is cancelled
+func (p *Parser) ParseTopicFilteredContext(ctx context.Context, query string, limit int, filter TopicFilter) ([]Talk, error) {
+	if filter.Sort != "" && !topicSorts[filter.Sort] {
+		return nil, fmt.Errorf("invalid sort %q: use newest, oldest, popular or relevance", filter.Sort)
+	}
+	if filter.MaxDuration < 0 {
+		return nil, fmt.Errorf("invalid max duration %s: must not be negative", filter.MaxDuration)
+	}
+
+	// TED filters by duration range; talks in the last range that are
+	// still too long are dropped here
+	var keep func(Talk) bool
+	if filter.MaxDuration > 0 {
+		keep = func(talk Talk) bool {
+			length, ok := talk.Length()
+			return !ok || length <= filter.MaxDuration
+		}
+	}
+	return p.parseTalksList(ctx, filter.apply(p.topicURL(query)), limit, keep)
+}
+
+// apply adds the query parameters of the filter to a talks list URL
+func (f TopicFilter) apply(listURL string) string {
+	params := url.Values{}
+	if f.Sort != "" {
+		params.Set("sort", f.Sort)
+	}
+	if f.Language != "" {
+		params.Set("language", f.Language)
+	}
+	if f.MaxDuration > 0 {
+		for _, bucket := range durationBuckets {
+			if bucket.min < f.MaxDuration {
+				params.Add("duration[]", bucket.param)
+			}
+		}
+	}
+	if len(params) == 0 {
+		return listURL
+	}
+	return listURL + "&" + params.Encode()
+}
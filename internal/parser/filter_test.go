@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopicFiltered(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`
+			<div class="media__message"><span class="thumb__duration">5:30</span>
+				<div class="media__message__title"><a href="/talks/short_talk">Short talk</a></div></div>
+			<div class="media__message"><span class="thumb__duration">11:02</span>
+				<div class="media__message__title"><a href="/talks/long_talk">Long talk</a></div></div>
+			<div class="media__message">
+				<div class="media__message__title"><a href="/talks/unknown_talk">Unknown length</a></div></div>`))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+
+	talks, err := p.ParseTopicFiltered("education", 10, TopicFilter{Sort: "newest", MaxDuration: 10 * time.Minute, Language: "es"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"education"}, query["topics[]"])
+	assert.Equal(t, "newest", query.Get("sort"))
+	assert.Equal(t, "es", query.Get("language"))
+	assert.Equal(t, []string{"0-6", "6-12"}, query["duration[]"])
+
+	// Talks known to be too long are dropped
+	var titles []string
+	for _, talk := range talks {
+		titles = append(titles, talk.Title)
+	}
+	assert.Equal(t, []string{"Short talk", "Unknown length"}, titles)
+
+	// Without a filter the URL is unchanged
+	_, err = p.ParseTopic("education", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{"topics[]": {"education"}}, query)
+
+	_, err = p.ParseTopicFiltered("education", 10, TopicFilter{Sort: "shortest"})
+	assert.ErrorContains(t, err, `invalid sort "shortest"`)
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/baiyutang/tedfetch/internal/logging"
+)
+
+// consentCookie records an accepted cookie banner, which makes TED skip the
+// consent interstitial in regions that show one
+const consentCookie = "OptanonAlertBoxClosed=2024-01-01T00:00:00.000Z; OptanonConsent=isGpcEnabled=0&groups=C0001:1,C0002:1,C0003:1,C0004:1"
+
+// Markers are matched against the lowercased page
+var (
+	// talkDataMarkers are only found on a real talk page
+	talkDataMarkers = []string{"talkpage.init", "__next_data__", "playerdata", "videoobject"}
+	// geoBlockMarkers are phrases of TED's "not available in your region" page
+	geoBlockMarkers = []string{
+		"not available in your country",
+		"not available in your region",
+		"unavailable in your country",
+		"unavailable in your region",
+	}
+	// consentMarkers identify a cookie consent interstitial
+	consentMarkers = []string{"onetrust-consent-sdk", "consent-wall", "cookie consent", "accept all cookies"}
+)
+
+// detectInterstitial returns ErrGeoBlocked or ErrConsentWall when rawHTML is
+// one of the pages TED serves in place of a talk, and nil otherwise
+func detectInterstitial(rawHTML []byte) error {
+	page := bytes.ToLower(rawHTML)
+	for _, marker := range talkDataMarkers {
+		if bytes.Contains(page, []byte(marker)) {
+			return nil
+		}
+	}
+	for _, marker := range geoBlockMarkers {
+		if bytes.Contains(page, []byte(marker)) {
+			return ErrGeoBlocked
+		}
+	}
+	for _, marker := range consentMarkers {
+		if bytes.Contains(page, []byte(marker)) {
+			return ErrConsentWall
+		}
+	}
+	return nil
+}
+
+// fetchPage fetches the HTML at url, sending cookie when it isn't empty
+func (p *Parser) fetchPage(ctx context.Context, url, cookie string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+	resp, err := p.do(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch talk page: %w", err)
+	}
+	defer logging.CloseBody(p.log(), resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrTalkNotFound, url)
+	case resp.StatusCode == http.StatusUnavailableForLegalReasons:
+		return nil, fmt.Errorf("%w: %s", ErrGeoBlocked, url)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch talk page: bad status: %s", resp.Status)
+	}
+
+	rawHTML, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTML response: %w", err)
+	}
+	return rawHTML, nil
+}
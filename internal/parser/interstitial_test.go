@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	consentPage = `<html><body><div id="onetrust-consent-sdk"><h1>We value your privacy</h1><button>Accept all cookies</button></div></body></html>`
+	geoPage     = `<html><body><h1>Sorry</h1><p>This talk is not available in your region.</p></body></html>`
+)
+
+func TestDetectInterstitial(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want error
+	}{
+		{"consent wall", consentPage, ErrConsentWall},
+		{"geo blocked", geoPage, ErrGeoBlocked},
+		{"talk page with cookie banner", `<div id="onetrust-consent-sdk"></div><script>talkPage.init({"playerData": {}})</script>`, nil},
+		{"unrelated page", `<html><h1>Test Title</h1></html>`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectInterstitial([]byte(tt.html)))
+		})
+	}
+}
+
+func TestParseURL_ConsentWall(t *testing.T) {
+	talkPage := `<html><h1>Test Title</h1><script>talkPage.init({"playerData": {"talks": [{"player_talks": [{"resources": {"h264": [{"quality": "720p", "file": "https://example.com/720p.mp4"}]}}]}]}})</script></html>`
+	graphqlError := []byte(`{"errors": [{"message": "Invalid slug", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`)
+
+	var cookies []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			_, _ = w.Write(graphqlError)
+			return
+		}
+		cookies = append(cookies, r.Header.Get("Cookie"))
+		if strings.Contains(r.Header.Get("Cookie"), "OptanonAlertBoxClosed") && !strings.Contains(r.URL.Path, "always") {
+			_, _ = w.Write([]byte(talkPage))
+			return
+		}
+		_, _ = w.Write([]byte(consentPage))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	// The consent page is fetched again with a consent cookie
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/720p.mp4", talk.VideoURLs["720p"])
+	assert.Equal(t, []string{"", consentCookie}, cookies)
+
+	// A consent page that won't go away is reported as such
+	_, err = p.ParseURL(mockServer.URL + "/talks/always_consent")
+	assert.True(t, errors.Is(err, ErrConsentWall))
+}
+
+func TestParseURL_GeoBlocked(t *testing.T) {
+	graphqlError := []byte(`{"errors": [{"message": "Invalid slug", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql":
+			_, _ = w.Write(graphqlError)
+		case "/talks/legal":
+			w.WriteHeader(http.StatusUnavailableForLegalReasons)
+		default:
+			_, _ = w.Write([]byte(geoPage))
+		}
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	_, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.True(t, errors.Is(err, ErrGeoBlocked))
+
+	_, err = p.ParseURL(mockServer.URL + "/talks/legal")
+	assert.True(t, errors.Is(err, ErrGeoBlocked))
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Language is a subtitle language of a talk
+type Language struct {
+	Code string `json:"code"` // lowercase language code, e.g. "zh-cn"
+	Name string `json:"name"` // display name, e.g. "Chinese, Simplified"
+}
+
+// subtitleLanguagesOperation is the operation name of subtitleLanguagesQuery
+const subtitleLanguagesOperation = "subtitleLanguages"
+
+// subtitleLanguagesQuery asks only for the subtitle languages of a talk,
+// without its video URLs or metadata
+const subtitleLanguagesQuery = `query subtitleLanguages($slug: String!) {
+	videos(
+		slug: [$slug]
+		first: 1
+		isPublished: [true, false]
+		channel: ALL
+	) {
+		nodes {
+			subtitledDownloads {
+				internalLanguageCode
+				languageName
+			}
+		}
+	}
+}`
+
+// ListSubtitleLanguages returns the subtitle languages of the talk with slug,
+// sorted by code, with a single GraphQL request. Languages without a display
+// name are named after their code.
+func (p *Parser) ListSubtitleLanguages(slug string) ([]Language, error) {
+	return p.ListSubtitleLanguagesContext(context.Background(), slug)
+}
+
+// ListSubtitleLanguagesContext is like ListSubtitleLanguages but aborts when ctx is cancelled
+func (p *Parser) ListSubtitleLanguagesContext(ctx context.Context, slug string) ([]Language, error) {
+	rawResp, err := p.queryGraphQL(ctx, subtitleLanguagesOperation, subtitleLanguagesQuery, map[string]interface{}{
+		"slug": slug,
+	}, p.TalkURL(slug))
+	if rawResp != nil {
+		p.storeRawResponse("languages_"+slug, rawResp)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subtitle languages: %w", err)
+	}
+
+	var result struct {
+		Data struct {
+			Videos struct {
+				Nodes []struct {
+					SubtitledDownloads []struct {
+						InternalLanguageCode string `json:"internalLanguageCode"`
+						LanguageName         string `json:"languageName"`
+					} `json:"subtitledDownloads"`
+				} `json:"nodes"`
+			} `json:"videos"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rawResp, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Data.Videos.Nodes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTalkNotFound, slug)
+	}
+
+	languages := []Language{}
+	seen := make(map[string]bool)
+	for _, sub := range result.Data.Videos.Nodes[0].SubtitledDownloads {
+		code := strings.ToLower(sub.InternalLanguageCode)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		name := sub.LanguageName
+		if name == "" {
+			name = code
+		}
+		languages = append(languages, Language{Code: code, Name: name})
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Code < languages[j].Code })
+	return languages, nil
+}
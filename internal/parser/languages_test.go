@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSubtitleLanguages(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		var body struct {
+			OperationName string                 `json:"operationName"`
+			Query         string                 `json:"query"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "subtitleLanguages", body.OperationName)
+		// No video URLs are asked for
+		assert.NotContains(t, body.Query, "nativeDownloads")
+		assert.NotContains(t, body.Query, "low")
+
+		if body.Variables["slug"] != "test_slug" {
+			_, _ = w.Write([]byte(`{"data": {"videos": {"nodes": []}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"videos": {"nodes": [{"subtitledDownloads": [
+			{"internalLanguageCode": "zh-CN", "languageName": "Chinese, Simplified"},
+			{"internalLanguageCode": "en", "languageName": "English"},
+			{"internalLanguageCode": "en", "languageName": "English"},
+			{"internalLanguageCode": "fr", "languageName": ""},
+			{"internalLanguageCode": "", "languageName": "Unknown"}
+		]}]}}}`))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.GraphqlURL = server.URL + "/graphql"
+
+	languages, err := p.ListSubtitleLanguages("test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, []Language{
+		{Code: "en", Name: "English"},
+		{Code: "fr", Name: "fr"},
+		{Code: "zh-cn", Name: "Chinese, Simplified"},
+	}, languages)
+	// A single GraphQL request, without the talk page
+	assert.Equal(t, []string{"/graphql"}, requests)
+
+	_, err = p.ListSubtitleLanguages("missing_slug")
+	assert.ErrorIs(t, err, ErrTalkNotFound)
+}
@@ -0,0 +1,22 @@
+package parser
+
+import (
+	"log/slog"
+
+	"github.com/baiyutang/tedfetch/internal/logging"
+)
+
+// SetLogger sets the logger receiving the parser's diagnostics; nil
+// discards them again
+func (p *Parser) SetLogger(logger *slog.Logger) {
+	p.logger = logger
+}
+
+// log returns the logger set with SetLogger. Without one, diagnostics are
+// discarded, or written to stderr in debug mode.
+func (p *Parser) log() *slog.Logger {
+	if p.logger == nil && p.Debug {
+		return logging.Debug
+	}
+	return logging.Or(p.logger)
+}
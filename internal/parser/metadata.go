@@ -0,0 +1,332 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// isoDurationPattern matches ISO 8601 durations such as "PT1H2M3S"
+var isoDurationPattern = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// extractMetadata fills description, duration, published date, view count,
+// speakers and related talks from the page.
+// Fields that are already set (e.g. from GraphQL) are left untouched.
+func (p *Parser) extractMetadata(doc *goquery.Document, talk *Talk) {
+	// Prefer the talkPage.init JSON data when the page carries it
+	if jsonData := findTalkPageJSON(doc); jsonData != "" {
+		var data struct {
+			PlayerData struct {
+				Talks []struct {
+					Duration    float64  `json:"duration"`
+					Published   int64    `json:"published"`
+					ViewedCount int64    `json:"viewed_count"`
+					Tags        []string `json:"tags"`
+					Related     []struct {
+						Slug string `json:"slug"`
+					} `json:"related_talks"`
+					Speakers []struct {
+						Firstname     string `json:"firstname"`
+						Middleinitial string `json:"middleinitial"`
+						Lastname      string `json:"lastname"`
+						Description   string `json:"description"`
+						WhoTheyAre    string `json:"whotheyare"`
+					} `json:"speakers"`
+				} `json:"talks"`
+			} `json:"playerData"`
+		}
+		if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
+			p.debugPrint("Failed to parse metadata JSON data: %v", err)
+		} else if len(data.PlayerData.Talks) > 0 {
+			t := data.PlayerData.Talks[0]
+			addTags(talk, t.Tags)
+			if talk.Duration == "" && t.Duration > 0 {
+				talk.Duration = formatDuration(int(t.Duration))
+			}
+			if talk.PublishedDate == "" && t.Published > 0 {
+				talk.PublishedDate = time.Unix(t.Published, 0).UTC().Format(time.DateOnly)
+			}
+			if talk.Views == "" && t.ViewedCount > 0 {
+				talk.Views = strconv.FormatInt(t.ViewedCount, 10)
+			}
+			if len(talk.Speakers) == 0 {
+				for _, speaker := range t.Speakers {
+					addSpeaker(talk, Speaker{
+						Name:  speakerName(speaker.Firstname, speaker.Middleinitial, speaker.Lastname),
+						Title: strings.TrimSpace(speaker.Description),
+						Bio:   cleanDescription(speaker.WhoTheyAre),
+					})
+				}
+				if talk.Speaker == "" {
+					talk.Speaker = speakerNames(talk.Speakers)
+				}
+			}
+			if len(talk.RelatedSlugs) == 0 {
+				related := make([]string, 0, len(t.Related))
+				for _, r := range t.Related {
+					related = append(related, r.Slug)
+				}
+				slug, _ := SlugFromURL(talk.URL)
+				addRelatedSlugs(talk, slug, related)
+			}
+		}
+	}
+
+	doc.Find(`meta[property="og:video:tag"]`).Each(func(i int, s *goquery.Selection) {
+		addTags(talk, []string{s.AttrOr("content", "")})
+	})
+
+	if talk.Description == "" {
+		description := doc.Find(`meta[property="og:description"]`).AttrOr("content", "")
+		if strings.TrimSpace(description) == "" {
+			description = doc.Find(`meta[name="description"]`).AttrOr("content", "")
+		}
+		talk.Description = cleanDescription(description)
+	}
+
+	// Fall back to the schema.org meta tags
+	if talk.Duration == "" {
+		if seconds, ok := parseISODuration(doc.Find(`meta[itemprop="duration"]`).AttrOr("content", "")); ok {
+			talk.Duration = formatDuration(seconds)
+		}
+	}
+	if talk.PublishedDate == "" {
+		talk.PublishedDate = formatPublishedDate(doc.Find(`meta[itemprop="uploadDate"]`).AttrOr("content", ""))
+	}
+	if talk.Views == "" {
+		count := doc.Find(`meta[itemprop="interactionCount"]`).AttrOr("content", "")
+		count = strings.TrimPrefix(count, "UserPlays:")
+		if _, err := strconv.ParseInt(count, 10, 64); err == nil {
+			talk.Views = count
+		}
+	}
+}
+
+// extractTitleAndSpeaker fills an empty title or speaker from the page's
+// JSON-LD, then its og:title ("Speaker: Title"), and only then from the
+// first <h1> and <h2>, whose order changes with TED's layout
+func extractTitleAndSpeaker(doc *goquery.Document, talk *Talk) {
+	if talk.Title == "" || talk.Speaker == "" {
+		title, speaker := findJSONLDVideo(doc)
+		if talk.Title == "" {
+			talk.Title = title
+		}
+		if talk.Speaker == "" {
+			talk.Speaker = speaker
+		}
+	}
+
+	if talk.Title == "" || talk.Speaker == "" {
+		ogTitle := strings.TrimSpace(doc.Find(`meta[property="og:title"]`).AttrOr("content", ""))
+		ogTitle = strings.TrimSuffix(ogTitle, " | TED Talk")
+		if speaker, title, ok := strings.Cut(ogTitle, ": "); ok {
+			if talk.Title == "" {
+				talk.Title = strings.TrimSpace(title)
+			}
+			if talk.Speaker == "" {
+				talk.Speaker = strings.TrimSpace(speaker)
+			}
+		} else if talk.Title == "" {
+			talk.Title = ogTitle
+		}
+	}
+
+	if talk.Title == "" {
+		talk.Title = strings.TrimSpace(doc.Find("h1").First().Text())
+	}
+	if talk.Speaker == "" {
+		talk.Speaker = strings.TrimSpace(doc.Find("h2").First().Text())
+	}
+}
+
+// findJSONLDVideo returns the name and author of the schema.org VideoObject
+// embedded in the page as JSON-LD, if any
+func findJSONLDVideo(doc *goquery.Document) (title, speaker string) {
+	type person struct {
+		Name string `json:"name"`
+	}
+	type videoObject struct {
+		Type   string          `json:"@type"`
+		Name   string          `json:"name"`
+		Author json.RawMessage `json:"author"`
+	}
+
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		raw := []byte(strings.TrimSpace(s.Text()))
+		var objects []videoObject
+		if err := json.Unmarshal(raw, &objects); err != nil {
+			var object videoObject
+			if err := json.Unmarshal(raw, &object); err != nil {
+				return true
+			}
+			objects = []videoObject{object}
+		}
+
+		for _, object := range objects {
+			if object.Type != "VideoObject" {
+				continue
+			}
+			title = strings.TrimSpace(object.Name)
+
+			// The author is either a single person or a list of people
+			var authors []person
+			if err := json.Unmarshal(object.Author, &authors); err != nil {
+				var author person
+				if err := json.Unmarshal(object.Author, &author); err == nil {
+					authors = []person{author}
+				}
+			}
+			names := make([]string, 0, len(authors))
+			for _, author := range authors {
+				if name := strings.TrimSpace(author.Name); name != "" {
+					names = append(names, name)
+				}
+			}
+			speaker = strings.Join(names, ", ")
+			return false
+		}
+		return true
+	})
+	return title, speaker
+}
+
+// speakerName joins the parts of a speaker's name, skipping empty ones
+func speakerName(parts ...string) string {
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// addSpeaker appends speaker to talk.Speakers unless it has no name
+func addSpeaker(talk *Talk, speaker Speaker) {
+	if speaker.Name != "" {
+		talk.Speakers = append(talk.Speakers, speaker)
+	}
+}
+
+// speakerNames joins the names of speakers for Talk.Speaker
+func speakerNames(speakers []Speaker) string {
+	names := make([]string, len(speakers))
+	for i, speaker := range speakers {
+		names[i] = speaker.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// maxRelatedTalks bounds Talk.RelatedSlugs so following them can't explode
+const maxRelatedTalks = 6
+
+// addRelatedSlugs appends slugs to talk.RelatedSlugs, skipping empty and
+// duplicate slugs and the talk's own slug, up to maxRelatedTalks
+func addRelatedSlugs(talk *Talk, ownSlug string, slugs []string) {
+	for _, slug := range slugs {
+		if len(talk.RelatedSlugs) >= maxRelatedTalks {
+			return
+		}
+		if slug == "" || slug == ownSlug || slices.Contains(talk.RelatedSlugs, slug) {
+			continue
+		}
+		talk.RelatedSlugs = append(talk.RelatedSlugs, slug)
+	}
+}
+
+// addTags appends tags to talk.Tags in lowercase, skipping empty and
+// duplicate tags
+func addTags(talk *Talk, tags []string) {
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(talk.Tags, tag) {
+			continue
+		}
+		talk.Tags = append(talk.Tags, tag)
+	}
+}
+
+// cleanDescription collapses the whitespace and line breaks of a talk description
+func cleanDescription(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// findTalkPageJSON returns the JSON object passed to talkPage.init, if any
+func findTalkPageJSON(doc *goquery.Document) string {
+	var jsonData string
+	doc.Find("script").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		text := s.Text()
+		if !strings.Contains(text, "talkPage.init") {
+			return true
+		}
+		start := strings.Index(text, "{")
+		end := strings.LastIndex(text, "}")
+		if start != -1 && end != -1 {
+			jsonData = text[start : end+1]
+		}
+		return false
+	})
+	return jsonData
+}
+
+// formatDuration renders a length in seconds the way TED displays it, e.g. "12:34" or "1:02:03"
+func formatDuration(seconds int) string {
+	h := seconds / 3600
+	m := (seconds % 3600) / 60
+	s := seconds % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// parseTEDDuration parses a duration as TED displays it, "12:34" or "1:02:03"
+func parseTEDDuration(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid duration %q: expected MM:SS or H:MM:SS", s)
+	}
+	total := 0
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q: expected MM:SS or H:MM:SS", s)
+		}
+		total = total*60 + n
+	}
+	return time.Duration(total) * time.Second, nil
+}
+
+// parseISODuration converts an ISO 8601 duration like "PT12M34S" to seconds
+func parseISODuration(s string) (int, bool) {
+	m := isoDurationPattern.FindStringSubmatch(s)
+	if m == nil || s == "PT" {
+		return 0, false
+	}
+	total := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, false
+		}
+		total += n * unit
+	}
+	return total, true
+}
+
+// formatPublishedDate normalizes an RFC 3339 timestamp to a YYYY-MM-DD date
+func formatPublishedDate(s string) string {
+	if s == "" {
+		return ""
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format(time.DateOnly)
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t.Format(time.DateOnly)
+	}
+	return ""
+}
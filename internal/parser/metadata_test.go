@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractMetadata_MetaTags(t *testing.T) {
+	html := `
+	<html><head>
+		<meta itemprop="duration" content="PT18M35S">
+		<meta itemprop="uploadDate" content="2011-01-03T15:14:00+00:00">
+		<meta itemprop="interactionCount" content="UserPlays:56012345">
+	</head><body><h1>Test Title</h1></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	assert.NoError(t, err)
+
+	talk := &Talk{}
+	New().extractMetadata(doc, talk)
+	assert.Equal(t, "18:35", talk.Duration)
+	assert.Equal(t, "2011-01-03", talk.PublishedDate)
+	assert.Equal(t, "56012345", talk.Views)
+}
+
+func TestExtractMetadata_RelatedTalks(t *testing.T) {
+	html := `
+	<html><script>
+	talkPage.init({
+		"playerData": {
+			"talks": [{
+				"related_talks": [
+					{"slug": "talk_one"},
+					{"slug": "this_talk"},
+					{"slug": "talk_one"},
+					{"slug": ""},
+					{"slug": "talk_two"}
+				]
+			}]
+		}
+	})
+	</script></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	assert.NoError(t, err)
+
+	talk := &Talk{URL: "https://www.ted.com/talks/this_talk"}
+	New().extractMetadata(doc, talk)
+	assert.Equal(t, []string{"talk_one", "talk_two"}, talk.RelatedSlugs)
+}
+
+func TestExtractMetadata_Speakers(t *testing.T) {
+	html := `
+	<html><script>
+	talkPage.init({
+		"playerData": {
+			"talks": [{
+				"speakers": [
+					{"firstname": "Ken", "lastname": "Robinson", "description": "Author/educator", "whotheyare": "Creativity expert."}
+				]
+			}]
+		}
+	})
+	</script></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	assert.NoError(t, err)
+
+	talk := &Talk{}
+	New().extractMetadata(doc, talk)
+	assert.Equal(t, "Ken Robinson", talk.Speaker)
+	assert.Equal(t, []Speaker{{Name: "Ken Robinson", Title: "Author/educator", Bio: "Creativity expert."}}, talk.Speakers)
+}
+
+func TestExtractTitleAndSpeaker(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "talk_page.html"))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		html        string
+		talk        Talk
+		wantTitle   string
+		wantSpeaker string
+	}{
+		{
+			name:        "current TED page",
+			html:        string(fixture),
+			wantTitle:   "The power of vulnerability",
+			wantSpeaker: "Brené Brown",
+		},
+		{
+			name:        "og:title only",
+			html:        `<html><head><meta property="og:title" content="Ken Robinson: Do schools kill creativity? | TED Talk"></head><body><h1>Menu</h1></body></html>`,
+			wantTitle:   "Do schools kill creativity?",
+			wantSpeaker: "Ken Robinson",
+		},
+		{
+			name:        "JSON-LD with several authors",
+			html:        `<html><script type="application/ld+json">[{"@type": "BreadcrumbList"}, {"@type": "VideoObject", "name": "A duet", "author": [{"name": "A"}, {"name": "B"}]}]</script></html>`,
+			wantTitle:   "A duet",
+			wantSpeaker: "A, B",
+		},
+		{
+			name:        "headings fallback",
+			html:        `<html><h1> Test Title </h1><h2>Test Speaker</h2></html>`,
+			wantTitle:   "Test Title",
+			wantSpeaker: "Test Speaker",
+		},
+		{
+			name:        "keeps values from GraphQL",
+			html:        string(fixture),
+			talk:        Talk{Title: "From GraphQL", Speaker: "GraphQL Speaker"},
+			wantTitle:   "From GraphQL",
+			wantSpeaker: "GraphQL Speaker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+			assert.NoError(t, err)
+
+			talk := tt.talk
+			extractTitleAndSpeaker(doc, &talk)
+			assert.Equal(t, tt.wantTitle, talk.Title)
+			assert.Equal(t, tt.wantSpeaker, talk.Speaker)
+		})
+	}
+}
+
+func TestExtractMetadata_Description(t *testing.T) {
+	html := `
+	<html><head>
+		<meta name="description" content="Generic site description">
+		<meta property="og:description" content="Brené Brown studies
+			human connection &amp; vulnerability.">
+	</head></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	assert.NoError(t, err)
+
+	talk := &Talk{}
+	New().extractMetadata(doc, talk)
+	assert.Equal(t, "Brené Brown studies human connection & vulnerability.", talk.Description)
+
+	// Plain description meta tag when og:description is missing
+	doc, err = goquery.NewDocumentFromReader(strings.NewReader(`<html><head><meta name="description" content="Fallback"></head></html>`))
+	assert.NoError(t, err)
+
+	talk = &Talk{}
+	New().extractMetadata(doc, talk)
+	assert.Equal(t, "Fallback", talk.Description)
+}
+
+func TestExtractMetadata_KeepsExistingFields(t *testing.T) {
+	html := `<html><head><meta itemprop="duration" content="PT1M"></head></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	assert.NoError(t, err)
+
+	talk := &Talk{Duration: "12:34", Description: "From GraphQL"}
+	New().extractMetadata(doc, talk)
+	assert.Equal(t, "12:34", talk.Duration)
+	assert.Equal(t, "From GraphQL", talk.Description)
+	assert.Empty(t, talk.PublishedDate)
+	assert.Empty(t, talk.Views)
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{0, "0:00"},
+		{59, "0:59"},
+		{754, "12:34"},
+		{3723, "1:02:03"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatDuration(tt.seconds))
+	}
+}
+
+func TestParseTEDDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"12:34", 12*time.Minute + 34*time.Second, false},
+		{"0:59", 59 * time.Second, false},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, false},
+		{" 4:05 ", 4*time.Minute + 5*time.Second, false},
+		{"", 0, true},
+		{"12", 0, true},
+		{"1:2:3:4", 0, true},
+		{"ab:cd", 0, true},
+		{"-1:00", 0, true},
+	}
+
+	for _, tt := range tests {
+		// Talk.Length reports the same, with ok instead of an error
+		talk := Talk{Duration: tt.in}
+		length, ok := talk.Length()
+		assert.Equal(t, !tt.wantErr, ok, tt.in)
+
+		got, err := parseTEDDuration(tt.in)
+		if tt.wantErr {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+		assert.Equal(t, tt.want, length, tt.in)
+	}
+}
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int
+		wantOK bool
+	}{
+		{"PT12M34S", 754, true},
+		{"PT1H2M3S", 3723, true},
+		{"PT45S", 45, true},
+		{"PT", 0, false},
+		{"12:34", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseISODuration(tt.in)
+		assert.Equal(t, tt.wantOK, ok, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
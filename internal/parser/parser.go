@@ -2,118 +2,488 @@ package parser
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"log/slog"
 	"net/http"
+	neturl "net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/baiyutang/tedfetch/internal/logging"
+	"github.com/baiyutang/tedfetch/internal/retry"
 )
 
 // Talk represents a TED talk with its metadata
 type Talk struct {
-	Title         string
-	Speaker       string
-	URL           string
-	Description   string
-	Duration      string
-	PublishedDate string
-	Views         string
-	// Video related fields
-	VideoURLs    map[string]string // quality -> URL
-	VideoFormats []VideoFormat     // Available video formats
-	// Subtitle related fields
-	SubtitleURLs map[string]string // language code -> URL
+	Title    string    `json:"title"`
+	Speaker  string    `json:"speaker"`  // Display name of all speakers
+	Speakers []Speaker `json:"speakers"` // Details of each speaker, when known
+	URL      string    `json:"url"`
+	ID       string    `json:"id,omitempty"` // Numeric video id, e.g. "399", used by GetSubtitle; empty if unknown
+	Slug     string    `json:"slug"`         // Last segment of the canonical URL, e.g. "ariel_ekblaw_how_to_build_in_space"
+	// CanonicalURL is TED's preferred URL of the talk, which differs from
+	// URL when an old or alternative URL was parsed
+	CanonicalURL  string `json:"canonical_url,omitempty"`
+	Description   string `json:"description"`
+	Duration      string `json:"duration"`
+	PublishedDate string `json:"published_date"`
+	Views         string `json:"views"`
+	// Video related fields. On the GraphQL path 360p/720p/1080p come from
+	// nativeDownloads low/medium/high; 720p/1080p fall back to the English
+	// subtitledDownloads low/high when no native file is offered.
+	VideoURLs    map[string]string `json:"video_urls"`              // quality -> URL
+	VideoFormats []VideoFormat     `json:"video_formats,omitempty"` // Available video formats
+	// Audio related fields
+	AudioURL string `json:"audio_url,omitempty"` // Audio-only download URL, empty if not offered
+	// Subtitle related fields. SubtitleURLs only lists subtitle files;
+	// videos with subtitles burned in are kept in SubtitledVideoURLs.
+	SubtitleURLs       map[string]string `json:"subtitle_urls"`                  // language code -> subtitle file URL
+	SubtitledVideoURLs map[string]string `json:"subtitled_video_urls,omitempty"` // language code -> video URL with those subtitles burned in
+	SubtitleLanguages  map[string]string `json:"subtitle_languages"`             // language code -> display name, e.g. "Chinese, Simplified"
+	// Transcript related fields
+	Transcript []TranscriptCue `json:"transcript,omitempty"` // Filled in by callers via GetTranscript
+	// RelatedSlugs lists up to maxRelatedTalks recommended talks
+	RelatedSlugs []string `json:"related_slugs,omitempty"`
+	// Tags are the talk's topics, lowercase, e.g. "science"
+	Tags []string `json:"tags,omitempty"`
+}
+
+// SubtitleCode returns the talk's code for the subtitle language lang,
+// matched case-insensitively so that "zh-CN" finds "zh-cn"
+func (t *Talk) SubtitleCode(lang string) (string, bool) {
+	if _, ok := t.SubtitleURLs[lang]; ok {
+		return lang, true
+	}
+	for code := range t.SubtitleURLs {
+		if strings.EqualFold(code, lang) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// Length returns the talk's Duration as a time.Duration, and false when the
+// duration is unknown
+func (t *Talk) Length() (time.Duration, bool) {
+	length, err := parseTEDDuration(t.Duration)
+	return length, err == nil
+}
+
+// SubtitleURL returns the subtitle URL for lang, matched case-insensitively
+func (t *Talk) SubtitleURL(lang string) (string, bool) {
+	code, ok := t.SubtitleCode(lang)
+	if !ok {
+		return "", false
+	}
+	return t.SubtitleURLs[code], true
+}
+
+// Speaker describes a person presenting a talk
+type Speaker struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"` // Occupation, e.g. "Vulnerability researcher"
+	Bio   string `json:"bio,omitempty"`
 }
 
 // VideoFormat represents a specific video format
 type VideoFormat struct {
-	Quality string // e.g., "1080p", "720p", "480p"
-	URL     string // Direct download URL
-	Size    int64  // File size in bytes
+	Quality string `json:"quality"` // e.g., "1080p", "720p", "480p"
+	URL     string `json:"url"`     // Direct download URL
+	Size    int64  `json:"size"`    // File size in bytes
+	// SHA256 is the hex SHA-256 of the file when known; downloads of the
+	// file are checked against it
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// TalkParser is the part of Parser the download command uses, so that it
+// can be replaced by a fake in tests
+type TalkParser interface {
+	ParseURLContext(ctx context.Context, url string) (*Talk, error)
+	ParseTopicContext(ctx context.Context, topic string, limit int) ([]Talk, error)
+	ParseTalkDetailsContext(ctx context.Context, title string) (*Talk, error)
+	ParsePlaylistContext(ctx context.Context, url string) (*Playlist, error)
+	SearchBySpeakerContext(ctx context.Context, name string, limit int) ([]Talk, error)
+	GetSubtitleContext(ctx context.Context, videoID, lang string) ([]byte, error)
+	TalkURL(slug string) string
 }
 
+var _ TalkParser = (*Parser)(nil)
+
 // Parser handles the parsing of TED talk pages
 type Parser struct {
 	client     *http.Client
-	GraphqlURL string
+	BaseURL    string // Site root used to build list, search and relative URLs
+	GraphqlURL string // GraphQL endpoint, <BaseURL>/graphql when empty
+	// GraphQLQuery replaces TalkQuery, e.g. to request more fields, which
+	// are then found in the raw response (see GetRawResponse). It takes the
+	// same variables and must still select the fields of TalkQuery.
+	GraphQLQuery string
+	// GraphQLOperation is the operation name of GraphQLQuery, TalkOperation by default
+	GraphQLOperation string
+	// UserAgent is sent with every request
+	UserAgent string
+	// Language of the title and description returned by GraphQL, e.g. "es"
+	// Language is also sent as the Accept-Language header, which localizes
+	// the titles of talk pages and talk lists.
+	Language string
+	// Cache, when set, is consulted by ParseURL before any request
+	Cache Cache
+	// MaxRetries is the number of attempts for each request on network
+	// errors and 5xx/429 responses
+	MaxRetries int
+	// RequestDelay is the minimum time between two requests, retries
+	// included, across all goroutines using the parser. 0 means no delay.
+	RequestDelay time.Duration
+	// retryDelay is the backoff before the first retry, see retry.Delay
+	retryDelay time.Duration
 	// Debug mode and response storage
 	Debug        bool
 	RawResponses map[string][]byte // Store raw responses for debugging
+	// AlwaysStoreRaw keeps the last maxRawResponses raw responses even
+	// without Debug, for troubleshooting
+	AlwaysStoreRaw bool
+	// MaxRawResponseBytes caps the total size of RawResponses; the least
+	// recently used responses are evicted first. 0 means no limit.
+	MaxRawResponseBytes int64
+	rawOrder            []string // keys of RawResponses, least recently used first
+	rawBytes            int64    // total size of RawResponses
+	rawLast             string   // key of the most recently stored raw response
+	logger              *slog.Logger
+	limiter             rateLimiter
 }
 
-var baseURL = "https://www.ted.com"
+// DefaultBaseURL is the TED site used when Parser.BaseURL is not overridden
+const DefaultBaseURL = "https://www.ted.com"
+
+// DefaultMaxRawResponseBytes is the default Parser.MaxRawResponseBytes
+const DefaultMaxRawResponseBytes = 32 << 20
+
+// DefaultLanguage is the metadata language used when Parser.Language is empty
+const DefaultLanguage = "en"
+
+// DefaultUserAgent is a desktop browser User-Agent; TED rejects some
+// requests carrying Go's default one
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
 
 // New creates a new Parser instance
 func New() *Parser {
+	return NewWithClient(&http.Client{})
+}
+
+// NewWithClient creates a new Parser that sends every request through client.
+// A nil client falls back to a default one.
+func NewWithClient(client *http.Client) *Parser {
+	if client == nil {
+		client = &http.Client{}
+	}
 	return &Parser{
-		client:       &http.Client{},
-		GraphqlURL:   "https://www.ted.com/graphql",
-		RawResponses: make(map[string][]byte),
+		client:              client,
+		BaseURL:             DefaultBaseURL,
+		GraphqlURL:          DefaultBaseURL + "/graphql",
+		UserAgent:           DefaultUserAgent,
+		Language:            DefaultLanguage,
+		MaxRetries:          3,
+		retryDelay:          500 * time.Millisecond,
+		MaxRawResponseBytes: DefaultMaxRawResponseBytes,
+		RawResponses:        make(map[string][]byte),
+	}
+}
+
+// SetClient replaces the HTTP client used for all requests, e.g. to set a
+// timeout, a proxy or a custom transport. A nil client is ignored.
+func (p *Parser) SetClient(client *http.Client) {
+	if client != nil {
+		p.client = client
+	}
+}
+
+// baseURL returns the configured site root without a trailing slash
+func (p *Parser) baseURL() string {
+	if p.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimSuffix(p.BaseURL, "/")
+}
+
+// graphqlURL returns the configured GraphQL endpoint or the one of the site root
+func (p *Parser) graphqlURL() string {
+	if p.GraphqlURL == "" {
+		return p.baseURL() + "/graphql"
+	}
+	return p.GraphqlURL
+}
+
+// language returns the configured metadata language or DefaultLanguage
+func (p *Parser) language() string {
+	if p.Language == "" {
+		return DefaultLanguage
+	}
+	return p.Language
+}
+
+// acceptLanguage returns the Accept-Language header for the configured
+// language, e.g. "es,en;q=0.8"
+func (p *Parser) acceptLanguage() string {
+	lang := p.language()
+	if strings.EqualFold(lang, DefaultLanguage) {
+		return lang
 	}
+	return lang + "," + DefaultLanguage + ";q=0.8"
 }
 
-// SetDebug enables or disables debug mode
+// TalkURL returns the URL of the talk page for slug
+func (p *Parser) TalkURL(slug string) string {
+	return p.baseURL() + "/talks/" + slug
+}
+
+// SetDebug enables or disables debug mode: every raw response is kept and,
+// without a logger, debug messages are written to stderr
 func (p *Parser) SetDebug(debug bool) {
 	p.Debug = debug
 }
 
-// debugPrint prints debug information if debug mode is enabled
+// debugPrint logs debug information, see SetLogger
 func (p *Parser) debugPrint(format string, args ...interface{}) {
-	if p.Debug {
-		fmt.Printf("[DEBUG] "+format+"\n", args...)
-	}
+	p.log().Debug(fmt.Sprintf(format, args...))
+}
+
+// warnPrint logs a problem the parser recovered from
+func (p *Parser) warnPrint(format string, args ...interface{}) {
+	p.log().Warn(fmt.Sprintf(format, args...))
 }
 
-// storeRawResponse stores raw response for debugging
-func (p *Parser) storeRawResponse(key string, data []byte) {
-	if p.Debug {
-		p.RawResponses[key] = data
+// fetch issues a GET request for url that is cancelled together with ctx
+func (p *Parser) fetch(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	return p.do(ctx, req)
 }
 
-// GetRawResponse returns stored raw response
-func (p *Parser) GetRawResponse(key string) []byte {
-	return p.RawResponses[key]
+// do sends req with the parser's client, retrying network errors and
+// 5xx/429 responses with exponential backoff. Cancellation of ctx is
+// reported instead of the underlying transport error. gzip and deflate
+// responses are decompressed. Accept-Language asks for Language, falling
+// back to English.
+func (p *Parser) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	attempts := p.MaxRetries
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	userAgent := p.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	if req.Header.Get("Accept-Language") == "" {
+		req.Header.Set("Accept-Language", p.acceptLanguage())
+	}
+
+	var lastErr error
+	var delay time.Duration
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			p.debugPrint("Retrying %s in %v (attempt %d/%d): %v", req.URL, delay, attempt+1, attempts, lastErr)
+			if err := retry.Sleep(ctx, delay); err != nil {
+				return nil, fmt.Errorf("request cancelled: %w", err)
+			}
+			// Rewind the request body for the next attempt
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("failed to rewind request body: %w", err)
+				}
+				req.Body = body
+			}
+		}
+
+		if err := p.limiter.wait(ctx, p.RequestDelay); err != nil {
+			return nil, fmt.Errorf("request cancelled: %w", err)
+		}
+		resp, err := p.client.Do(req)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("request cancelled: %w", ctxErr)
+			}
+			lastErr = err
+			delay = retry.Delay(p.retryDelay, attempt)
+			continue
+		}
+
+		if !retry.Retryable(resp.StatusCode) {
+			if err := decodeBody(resp); err != nil {
+				logging.CloseBody(p.log(), resp.Body)
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		lastErr = fmt.Errorf("bad status: %s", resp.Status)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			lastErr = fmt.Errorf("%w: bad status: %s", ErrRateLimited, resp.Status)
+		}
+		var ok bool
+		if delay, ok = retry.After(resp); !ok {
+			delay = retry.Delay(p.retryDelay, attempt)
+		}
+		logging.CloseBody(p.log(), resp.Body)
+	}
+
+	return nil, lastErr
 }
 
-// ParseTopic fetches and parses TED talks for a given topic or title
+// ParseTopic fetches and parses TED talks for a given topic or title.
+// Only the list page is fetched, so the returned talks carry title, speaker,
+// URL and, when shown, duration; use EnrichTalk for video and subtitle URLs.
 func (p *Parser) ParseTopic(query string, limit int) ([]Talk, error) {
+	return p.ParseTopicContext(context.Background(), query, limit)
+}
+
+// ParseTopicContext is like ParseTopic but aborts when ctx is cancelled
+func (p *Parser) ParseTopicContext(ctx context.Context, query string, limit int) ([]Talk, error) {
+	return p.ParseTopicFilteredContext(ctx, query, limit, TopicFilter{})
+}
+
+// EnrichTalk fetches the talk page of a talk returned by ParseTopic and
+// fills in its video URLs, subtitle URLs and remaining metadata
+func (p *Parser) EnrichTalk(talk *Talk) error {
+	return p.EnrichTalkContext(context.Background(), talk)
+}
+
+// EnrichTalkContext is like EnrichTalk but aborts when ctx is cancelled
+func (p *Parser) EnrichTalkContext(ctx context.Context, talk *Talk) error {
+	details, err := p.ParseURLContext(ctx, talk.URL)
+	if err != nil {
+		return fmt.Errorf("failed to parse talk details for %s: %w", talk.URL, err)
+	}
+
+	// Keep what the list page already told us when the talk page is vaguer
+	title, speaker, duration := talk.Title, talk.Speaker, talk.Duration
+	*talk = *details
+	if title != "" {
+		talk.Title = title
+	}
+	if speaker != "" {
+		talk.Speaker = speaker
+	}
+	if talk.Duration == "" {
+		talk.Duration = duration
+	}
+	return nil
+}
+
+// topicURL builds the talks list URL for a topic, or the search URL for a title
+func (p *Parser) topicURL(query string) string {
 	// If the query looks like a title, search by title
 	if !strings.Contains(query, " ") {
-		url := fmt.Sprintf("%s/talks?topics[]=%s", baseURL, query)
-		return p.parseTalksList(url, limit)
+		return fmt.Sprintf("%s/talks?topics[]=%s", p.baseURL(), query)
 	}
 
 	// Otherwise, search by title
-	url := fmt.Sprintf("%s/search?q=%s", baseURL, strings.ReplaceAll(query, " ", "+"))
-	return p.parseTalksList(url, limit)
+	return fmt.Sprintf("%s/search?q=%s", p.baseURL(), strings.ReplaceAll(query, " ", "+"))
 }
 
-// parseTalksList fetches and parses the list of talks from a given URL
-func (p *Parser) parseTalksList(url string, limit int) ([]Talk, error) {
-	resp, err := p.client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch talks list: %w", err)
+// maxListPages bounds how many pages of a talks list are followed
+const maxListPages = 20
+
+// largeListLimit is the limit past which listing talks warns that it
+// fetches many pages and may still return fewer talks
+const largeListLimit = 500
+
+// parseTalksList fetches and parses the list of talks from a given URL,
+// following pagination until limit talks are collected or pages run out.
+// Talks listed more than once are returned once, in first-seen order.
+// When keep is not nil, only the talks it accepts are collected.
+// A limit below 1 returns ErrInvalidLimit.
+func (p *Parser) parseTalksList(ctx context.Context, url string, limit int, keep func(Talk) bool) ([]Talk, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("%w %d: must be at least 1", ErrInvalidLimit, limit)
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Println("close response body error:", cerr)
+	if limit > largeListLimit {
+		p.log().Warn("large limit, fetching many pages of results", "limit", limit, "max_pages", maxListPages)
+	}
+
+	var talks []Talk
+	seen := make(map[string]bool)
+	pageURL := url
+	for page := 1; page <= maxListPages && len(talks) < limit; page++ {
+		p.debugPrint("Fetching talks list page %d: %s", page, pageURL)
+		doc, err := p.fetchDocument(ctx, pageURL)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("parsing talks list cancelled: %w", ctxErr)
+			}
+			if errors.Is(err, ErrPageNotFound) && page > 1 {
+				// Numbered past the last page
+				break
+			}
+			return nil, fmt.Errorf("failed to fetch talks list: %w", err)
 		}
-	}()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+		added := 0
+		for _, talk := range p.parseTalksPage(doc) {
+			if len(talks) >= limit {
+				break
+			}
+			// The same talk can be listed twice, e.g. matching both
+			// title and speaker, under differently written URLs
+			key := talk.Slug
+			if key == "" {
+				key = talk.URL
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			added++
+			if keep == nil || keep(talk) {
+				talks = append(talks, talk)
+			}
+		}
+
+		// Stop when a page adds nothing new, past the last page or in case "next" loops back
+		if added == 0 {
+			break
+		}
+
+		// Follow the "next" link, or number the page ourselves when the
+		// results are paginated without one
+		if next, ok := doc.Find(`a[rel="next"], a.pagination__next`).First().Attr("href"); ok && next != "" {
+			if !strings.HasPrefix(next, "http") {
+				next = p.baseURL() + next
+			}
+			pageURL = next
+		} else if doc.Find(`a[href*="page="]`).Length() > 0 {
+			pageURL = withPage(url, page+1)
+		} else {
+			break
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("parsing talks list cancelled: %w", err)
 	}
 
+	return talks, nil
+}
+
+// parseTalksPage returns the talks listed on one page of topic or search results
+func (p *Parser) parseTalksPage(doc *goquery.Document) []Talk {
 	var talks []Talk
 	doc.Find(".media__message, .search__result").Each(func(i int, s *goquery.Selection) {
-		if i >= limit {
-			return
-		}
-
 		var titleLink *goquery.Selection
 		if s.HasClass("search__result") {
 			titleLink = s.Find("h3 a")
@@ -125,113 +495,112 @@ func (p *Parser) parseTalksList(url string, limit int) ([]Talk, error) {
 		speaker := strings.TrimSpace(s.Find(".media__message__speaker h4, .search__result__speaker").Text())
 		url, _ := titleLink.Attr("href")
 		if !strings.HasPrefix(url, "http") {
-			url = baseURL + url
+			url = p.baseURL() + url
 		}
 
-		talk := Talk{
-			Title:   title,
-			Speaker: speaker,
-			URL:     url,
-		}
-
-		// Parse individual talk page to get video and subtitle URLs
-		if err := p.parseTalkDetails(&talk); err != nil {
-			fmt.Printf("Warning: failed to parse talk details for %s: %v\n", url, err)
-		}
-
-		talks = append(talks, talk)
+		slug, _ := SlugFromURL(url)
+		talks = append(talks, Talk{
+			Title:    title,
+			Speaker:  speaker,
+			URL:      url,
+			Slug:     slug,
+			Duration: strings.TrimSpace(s.Find(".thumb__duration").First().Text()),
+		})
 	})
-
-	return talks, nil
+	return talks
 }
 
-// parseTalkDetails fetches and parses the individual talk page to get video and subtitle URLs
-func (p *Parser) parseTalkDetails(talk *Talk) error {
-	resp, err := p.client.Get(talk.URL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch talk page: %w", err)
+// withPage adds the page query parameter to a list URL
+func withPage(url string, page int) string {
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Println("close response body error:", cerr)
-		}
-	}()
+	return fmt.Sprintf("%s%spage=%d", url, sep, page)
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to parse talk page: %w", err)
-	}
+// qualityAliases maps TED's named qualities to resolutions
+var qualityAliases = map[string]string{
+	"low":    "360p",
+	"medium": "720p",
+	"high":   "1080p",
+	"sd":     "480p",
+	"hd":     "720p",
+}
 
-	// Extract video URLs from the page's JSON data
-	if err := p.extractVideoURLs(doc, talk); err != nil {
-		return fmt.Errorf("failed to extract video URLs: %w", err)
+// NormalizeQuality returns the canonical form of a video quality, such as
+// "360p", "480p", "720p" or "1080p", so that "medium", "720P" and "720"
+// all become "720p". Unknown qualities are returned lowercased.
+func NormalizeQuality(quality string) string {
+	q := strings.ToLower(strings.TrimSpace(quality))
+	if alias, ok := qualityAliases[q]; ok {
+		return alias
 	}
-
-	// Extract subtitle URLs
-	if err := p.extractSubtitleURLs(doc, talk); err != nil {
-		return fmt.Errorf("failed to extract subtitle URLs: %w", err)
+	if n, err := strconv.Atoi(strings.TrimSuffix(q, "p")); err == nil && n > 0 {
+		return strconv.Itoa(n) + "p"
 	}
-
-	return nil
+	return q
 }
 
-// extractVideoURLs extracts video download URLs from the page's JSON data
+// extractVideoURLs extracts video download URLs from the page's JSON data.
+// A page without talkPage.init data is not an error; data that fails to
+// decode is reported as ErrUnexpectedFormat.
 func (p *Parser) extractVideoURLs(doc *goquery.Document, talk *Talk) error {
 	// Find the script tag containing video data
-	doc.Find("script").Each(func(i int, s *goquery.Selection) {
-		text := s.Text()
-		if strings.Contains(text, "talkPage.init") {
-			// Extract JSON data from the script
-			start := strings.Index(text, "{")
-			end := strings.LastIndex(text, "}")
-			if start != -1 && end != -1 {
-				jsonData := text[start : end+1]
-				var data struct {
-					PlayerData struct {
-						Talks []struct {
-							PlayerTalks []struct {
-								Resources struct {
-									H264 []struct {
-										Quality string `json:"quality"`
-										Size    int64  `json:"size"`
-										URL     string `json:"file"`
-									} `json:"h264"`
-								} `json:"resources"`
-							} `json:"player_talks"`
-						} `json:"talks"`
-					} `json:"playerData"`
-				}
+	jsonData := findTalkPageJSON(doc)
+	if jsonData == "" {
+		return nil
+	}
 
-				if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
-					p.debugPrint("Failed to parse video JSON data: %v", err)
-					return
-				}
+	var data struct {
+		PlayerData struct {
+			Talks []struct {
+				PlayerTalks []struct {
+					Resources struct {
+						H264 []struct {
+							Quality string `json:"quality"`
+							Size    int64  `json:"size"`
+							URL     string `json:"file"`
+						} `json:"h264"`
+					} `json:"resources"`
+				} `json:"player_talks"`
+			} `json:"talks"`
+		} `json:"playerData"`
+	}
 
-				// Extract video formats
-				if len(data.PlayerData.Talks) > 0 && len(data.PlayerData.Talks[0].PlayerTalks) > 0 {
-					resources := data.PlayerData.Talks[0].PlayerTalks[0].Resources
-					for _, h264 := range resources.H264 {
-						talk.VideoFormats = append(talk.VideoFormats, VideoFormat{
-							Quality: h264.Quality,
-							URL:     h264.URL,
-							Size:    h264.Size,
-						})
-						// Also add to VideoURLs map
-						if talk.VideoURLs == nil {
-							talk.VideoURLs = make(map[string]string)
-						}
-						talk.VideoURLs[h264.Quality] = h264.URL
-					}
-				}
+	if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
+		return fmt.Errorf("%w: failed to decode talkPage.init data: %w", ErrUnexpectedFormat, err)
+	}
+
+	// Extract video formats
+	if len(data.PlayerData.Talks) > 0 && len(data.PlayerData.Talks[0].PlayerTalks) > 0 {
+		resources := data.PlayerData.Talks[0].PlayerTalks[0].Resources
+		for _, h264 := range resources.H264 {
+			quality := NormalizeQuality(h264.Quality)
+			talk.VideoFormats = append(talk.VideoFormats, VideoFormat{
+				Quality: quality,
+				URL:     h264.URL,
+				Size:    h264.Size,
+			})
+			// Also add to VideoURLs map, keeping the first file of a quality
+			if talk.VideoURLs == nil {
+				talk.VideoURLs = make(map[string]string)
+			}
+			if _, ok := talk.VideoURLs[quality]; !ok && h264.URL != "" {
+				talk.VideoURLs[quality] = h264.URL
 			}
 		}
-	})
+	}
 	return nil
 }
 
-// extractSubtitleURLs extracts subtitle download URLs from the page
+// extractSubtitleURLs extracts subtitle download URLs from the page. Links
+// to videos are kept apart in SubtitledVideoURLs; links to subtitle pages,
+// which aren't files, only name the language.
 func (p *Parser) extractSubtitleURLs(doc *goquery.Document, talk *Talk) error {
 	talk.SubtitleURLs = make(map[string]string)
+	talk.SubtitledVideoURLs = make(map[string]string)
+	talk.SubtitleLanguages = make(map[string]string)
 
 	// Find subtitle links in the page
 	doc.Find("a[data-language]").Each(func(i int, s *goquery.Selection) {
@@ -239,82 +608,151 @@ func (p *Parser) extractSubtitleURLs(doc *goquery.Document, talk *Talk) error {
 		url, exists := s.Attr("href")
 		if exists && lang != "" {
 			if !strings.HasPrefix(url, "http") {
-				url = baseURL + url
+				url = p.baseURL() + url
+			}
+			switch {
+			case isVideoURL(url):
+				talk.SubtitledVideoURLs[lang] = url
+			case isSubtitleFileURL(url):
+				talk.SubtitleURLs[lang] = url
+			default:
+				p.debugPrint("Skipping subtitle page %s (%s), not a subtitle file", url, lang)
+			}
+			if name := strings.TrimSpace(s.Text()); name != "" {
+				talk.SubtitleLanguages[lang] = name
 			}
-			talk.SubtitleURLs[lang] = url
 		}
 	})
 
 	return nil
 }
 
-// ParseURL parses a TED talk page directly from its URL
-func (p *Parser) ParseURL(url string) (*Talk, error) {
-	// Extract slug from URL
-	u := strings.SplitN(url, "?", 2)[0] // Remove query parameters
-	parts := strings.Split(u, "/")
-	slug := ""
-	for i := len(parts) - 1; i >= 0; i-- {
-		if parts[i] != "" {
-			slug = parts[i]
-			break
+// subtitleFileURL returns the SubRip subtitles of the video with the
+// numeric id in lang, e.g. /talks/subtitles/id/399/lang/en.srt
+func (p *Parser) subtitleFileURL(id, lang string) string {
+	return fmt.Sprintf("%s/talks/subtitles/id/%s/lang/%s.srt", p.baseURL(), neturl.PathEscape(id), neturl.PathEscape(lang))
+}
+
+// isVideoURL reports whether url points to a video file rather than a
+// subtitle file or page
+func isVideoURL(rawURL string) bool {
+	if u, err := neturl.Parse(rawURL); err == nil {
+		rawURL = u.Path
+	}
+	switch strings.ToLower(path.Ext(rawURL)) {
+	case ".mp4", ".m4v", ".mov", ".webm":
+		return true
+	}
+	return false
+}
+
+// isSubtitleFileURL reports whether url points to a subtitle file rather
+// than a page about the subtitles
+func isSubtitleFileURL(rawURL string) bool {
+	if u, err := neturl.Parse(rawURL); err == nil {
+		rawURL = u.Path
+	}
+	switch strings.ToLower(path.Ext(rawURL)) {
+	case ".srt", ".vtt":
+		return true
+	}
+	return false
+}
+
+// setCanonicalURL records TED's canonical URL of a talk and takes the slug
+// from it, so a talk is named the same whichever of its URLs was parsed.
+// URLs that aren't talk URLs are ignored.
+func setCanonicalURL(talk *Talk, canonicalURL string) {
+	slug, err := SlugFromURL(canonicalURL)
+	if err != nil {
+		return
+	}
+	talk.CanonicalURL = canonicalURL
+	talk.Slug = slug
+}
+
+// talkSubpages are the pages below a talk, e.g. /talks/<slug>/transcript
+var talkSubpages = map[string]bool{
+	"transcript": true,
+	"details":    true,
+	"up-next":    true,
+}
+
+// SlugFromURL returns the slug of a TED talk URL, the last path segment of
+// its talkPageURL, or ErrInvalidURL if url is not under /talks/
+func SlugFromURL(url string) (string, error) {
+	u := strings.SplitN(talkPageURL(url), "?", 2)[0] // Remove query parameters
+	var parts []string
+	for _, part := range strings.Split(u, "/") {
+		if part != "" {
+			parts = append(parts, part)
 		}
 	}
+	slug := ""
+	if n := len(parts); n > 0 {
+		slug = parts[n-1]
+	}
 	// Slug must not be empty, must not be a domain, and must be under /talks/
 	if slug == "" || strings.Contains(slug, ".") || !strings.Contains(u, "/talks/") {
-		return nil, fmt.Errorf("invalid TED talk URL")
+		return "", fmt.Errorf("%w: %s", ErrInvalidURL, url)
+	}
+	return slug, nil
+}
+
+// talkPageURL returns url without its #fragment and, for a subpage of a
+// talk, the URL of the talk page itself, e.g. /talks/<slug>?language=fr for
+// /talks/<slug>/transcript?language=fr#t-60000
+func talkPageURL(url string) string {
+	url, _, _ = strings.Cut(url, "#")
+	u, query, hasQuery := strings.Cut(url, "?")
+	trimmed := strings.TrimSuffix(u, "/")
+	if i := strings.LastIndex(trimmed, "/"); i >= 0 && talkSubpages[trimmed[i+1:]] && !strings.HasSuffix(trimmed[:i], "/talks") {
+		u = trimmed[:i]
+	}
+	if hasQuery {
+		u += "?" + query
+	}
+	return u
+}
+
+// ParseURL parses a TED talk page directly from its URL
+func (p *Parser) ParseURL(url string) (*Talk, error) {
+	return p.ParseURLContext(context.Background(), url)
+}
+
+// ParseURLContext is like ParseURL but aborts when ctx is cancelled
+func (p *Parser) ParseURLContext(ctx context.Context, url string) (*Talk, error) {
+	url = talkPageURL(url)
+	slug, err := SlugFromURL(url)
+	if err != nil {
+		return nil, err
 	}
 	p.debugPrint("Processing slug: %s", slug)
 
 	// Try GraphQL first
-	talk, err := p.parseWithGraphQL(slug, url)
+	talk, err := p.parseWithGraphQL(ctx, slug, url)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("parsing talk cancelled: %w", ctxErr)
+		}
 		p.debugPrint("GraphQL parsing failed: %v", err)
+		if !shouldFallback(err) {
+			return nil, fmt.Errorf("failed to query talk: %w", err)
+		}
 		// Fallback to HTML parsing
 		p.debugPrint("Falling back to HTML parsing")
-		return p.parseWithHTML(url)
+		return p.parseWithHTML(ctx, slug, url)
 	}
 	return talk, nil
 }
 
-// parseWithGraphQL attempts to parse using GraphQL API
-func (p *Parser) parseWithGraphQL(slug, url string) (*Talk, error) {
-	// Create GraphQL request
-	query := `query shareLinks($slug: String!, $language: String) {
-		videos(
-			slug: [$slug]
-			language: $language
-			first: 1
-			isPublished: [true, false]
-			channel: ALL
-		) {
-			nodes {
-				id
-				canonicalUrl
-				audioDownload
-				nativeDownloads {
-					low
-					medium
-					high
-				}
-				subtitledDownloads {
-					low
-					high
-					internalLanguageCode
-					languageName
-				}
-			}
-		}
-	}`
-
+// postGraphQL sends a GraphQL operation to TED and returns the raw response body
+func (p *Parser) postGraphQL(ctx context.Context, operationName, query string, variables map[string]interface{}, referer string) ([]byte, error) {
 	// Create request body
 	reqBody := map[string]interface{}{
-		"operationName": "shareLinks",
-		"variables": map[string]interface{}{
-			"slug":     slug,
-			"language": "en",
-		},
-		"query": query,
+		"operationName": operationName,
+		"variables":     variables,
+		"query":         query,
 	}
 
 	// Convert request body to JSON
@@ -324,7 +762,7 @@ func (p *Parser) parseWithGraphQL(slug, url string) (*Talk, error) {
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", p.GraphqlURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.graphqlURL(), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -332,141 +770,438 @@ func (p *Parser) parseWithGraphQL(slug, url string) (*Talk, error) {
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Origin", "https://www.ted.com")
-	req.Header.Set("Referer", url)
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-	req.Header.Set("X-Operation-Name", "shareLinks")
+	req.Header.Set("Origin", p.baseURL())
+	req.Header.Set("Referer", referer)
+	req.Header.Set("X-Operation-Name", operationName)
 
 	// Send request
-	resp, err := p.client.Do(req)
+	resp, err := p.do(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Println("close response body error:", cerr)
-		}
-	}()
+	defer logging.CloseBody(p.log(), resp.Body)
 
-	// Read and store raw response
+	// Read raw response
 	rawResp, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
-	p.storeRawResponse("graphql_"+slug, rawResp)
+	return rawResp, nil
+}
+
+// graphQLErrors is the "errors" member of a GraphQL response
+type graphQLErrors []struct {
+	Message    string `json:"message"`
+	Extensions struct {
+		Code string `json:"code"`
+	} `json:"extensions"`
+}
+
+// err returns the first error as a *GraphQLError, or nil if there is none
+func (errs graphQLErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &GraphQLError{Code: errs[0].Extensions.Code, Message: errs[0].Message}
+}
+
+// queryGraphQL is like postGraphQL but returns a *GraphQLError, together with
+// the raw response, when the response carries errors
+func (p *Parser) queryGraphQL(ctx context.Context, operationName, query string, variables map[string]interface{}, referer string) ([]byte, error) {
+	rawResp, err := p.postGraphQL(ctx, operationName, query, variables, referer)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Errors graphQLErrors `json:"errors"`
+	}
+	if err := json.Unmarshal(rawResp, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return rawResp, result.Errors.err()
+}
+
+// shouldFallback reports whether the talk page is worth fetching after the
+// GraphQL query failed with err. GraphQL errors (e.g. a validation failure
+// after a schema change), network errors and empty responses fall back;
+// rate limiting, which has already been retried, would throttle the page
+// request too.
+func shouldFallback(err error) bool {
+	return !errors.Is(err, ErrRateLimited)
+}
+
+// TalkOperation is the operation name of TalkQuery
+const TalkOperation = "shareLinks"
+
+// TalkQuery is the GraphQL query ParseURL sends for a talk, with the
+// variables $slug and $language. Parser.GraphQLQuery replaces it.
+const TalkQuery = `query shareLinks($slug: String!, $language: String) {
+	videos(
+		slug: [$slug]
+		language: $language
+		first: 1
+		isPublished: [true, false]
+		channel: ALL
+	) {
+		nodes {
+			id
+			title
+			canonicalUrl
+			description
+			duration
+			publishedAt
+			viewedCount
+			audioDownload
+			nativeDownloads {
+				low
+				medium
+				high
+			}
+			subtitledDownloads {
+				low
+				high
+				internalLanguageCode
+				languageName
+			}
+		}
+	}
+}`
+
+// TalkDetailsOperation is the operation name of TalkDetailsQuery
+const TalkDetailsOperation = "talkDetails"
+
+// TalkDetailsQuery asks for the speakers, related talks and topics of a
+// talk. These fields are not part of the query TED's own share links send,
+// so they are fetched separately: if TED rejects them, the talk still
+// parses and the details come from the talk page instead.
+const TalkDetailsQuery = `query talkDetails($slug: String!, $language: String) {
+	videos(
+		slug: [$slug]
+		language: $language
+		first: 1
+		isPublished: [true, false]
+		channel: ALL
+	) {
+		nodes {
+			presenterDisplayName
+			speakers {
+				nodes {
+					firstname
+					middleinitial
+					lastname
+					description
+					whoTheyAre
+				}
+			}
+			relatedVideos {
+				slug
+			}
+			topics {
+				nodes {
+					name
+				}
+			}
+		}
+	}
+}`
+
+// talkNode is a video node of TalkQuery or TalkDetailsQuery
+type talkNode struct {
+	ID                   string `json:"id"`
+	Title                string `json:"title"`
+	CanonicalURL         string `json:"canonicalUrl"`
+	Description          string `json:"description"`
+	PresenterDisplayName string `json:"presenterDisplayName"`
+	Speakers             struct {
+		Nodes []struct {
+			Firstname     string `json:"firstname"`
+			Middleinitial string `json:"middleinitial"`
+			Lastname      string `json:"lastname"`
+			Description   string `json:"description"`
+			WhoTheyAre    string `json:"whoTheyAre"`
+		} `json:"nodes"`
+	} `json:"speakers"`
+	Duration        float64 `json:"duration"`
+	PublishedAt     string  `json:"publishedAt"`
+	ViewedCount     int64   `json:"viewedCount"`
+	AudioDownload   string  `json:"audioDownload"`
+	NativeDownloads struct {
+		Low    string `json:"low"`
+		Medium string `json:"medium"`
+		High   string `json:"high"`
+	} `json:"nativeDownloads"`
+	SubtitledDownloads []struct {
+		Low                  string `json:"low"`
+		High                 string `json:"high"`
+		InternalLanguageCode string `json:"internalLanguageCode"`
+		LanguageName         string `json:"languageName"`
+	} `json:"subtitledDownloads"`
+	RelatedVideos []struct {
+		Slug string `json:"slug"`
+	} `json:"relatedVideos"`
+	Topics struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"topics"`
+}
+
+// talkQuery returns the operation name and query to send for a talk
+func (p *Parser) talkQuery() (string, string) {
+	if p.GraphQLQuery == "" {
+		return TalkOperation, TalkQuery
+	}
+	operation := p.GraphQLOperation
+	if operation == "" {
+		operation = TalkOperation
+	}
+	return operation, p.GraphQLQuery
+}
+
+// queryTalk sends a talk query for slug, unless its response is cached
+// under cacheKey, and returns the first video node
+func (p *Parser) queryTalk(ctx context.Context, cacheKey, operation, query, slug, url string) (*talkNode, error) {
+	lang := p.language()
+	if lang != DefaultLanguage {
+		cacheKey += "_" + lang
+	}
+	if query != TalkQuery && query != TalkDetailsQuery {
+		// Don't serve the response of another query from the cache
+		cacheKey += fmt.Sprintf("_%08x", crc32.ChecksumIEEE([]byte(query)))
+	}
+	rawResp, cached := p.cacheGet(cacheKey)
+	var err error
+	if !cached {
+		rawResp, err = p.queryGraphQL(ctx, operation, query, map[string]interface{}{
+			"slug":     slug,
+			"language": lang,
+		}, url)
+	}
+	// Error responses are kept too, for debugging
+	if rawResp != nil {
+		p.storeRawResponse(cacheKey, rawResp)
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse response
 	var result struct {
 		Data struct {
 			Videos struct {
-				Nodes []struct {
-					NativeDownloads struct {
-						Low    string `json:"low"`
-						Medium string `json:"medium"`
-						High   string `json:"high"`
-					} `json:"nativeDownloads"`
-					SubtitledDownloads []struct {
-						Low                  string `json:"low"`
-						High                 string `json:"high"`
-						InternalLanguageCode string `json:"internalLanguageCode"`
-						LanguageName         string `json:"languageName"`
-					} `json:"subtitledDownloads"`
-				} `json:"nodes"`
+				Nodes []talkNode `json:"nodes"`
 			} `json:"videos"`
 		} `json:"data"`
-		Errors []struct {
-			Message string `json:"message"`
-		} `json:"errors"`
 	}
-
 	if err := json.Unmarshal(rawResp, &result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	if len(result.Errors) > 0 {
-		return nil, fmt.Errorf("GraphQL error: %s", result.Errors[0].Message)
-	}
-
 	if len(result.Data.Videos.Nodes) == 0 {
 		return nil, fmt.Errorf("no video data found")
 	}
+	if !cached {
+		p.cacheSet(cacheKey, rawResp)
+	}
+	return &result.Data.Videos.Nodes[0], nil
+}
+
+// parseWithGraphQL attempts to parse using GraphQL API
+func (p *Parser) parseWithGraphQL(ctx context.Context, slug, url string) (*Talk, error) {
+	operation, query := p.talkQuery()
+	node, err := p.queryTalk(ctx, "graphql_"+slug, operation, query, slug, url)
+	if err != nil {
+		return nil, err
+	}
+
+	// A custom query asks for whatever details it needs itself
+	if query == TalkQuery {
+		details, err := p.queryTalk(ctx, "graphql_details_"+slug, TalkDetailsOperation, TalkDetailsQuery, slug, url)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("parsing talk cancelled: %w", ctxErr)
+			}
+			p.debugPrint("Failed to get talk details from GraphQL: %v", err)
+		} else {
+			node.PresenterDisplayName = details.PresenterDisplayName
+			node.Speakers = details.Speakers
+			node.RelatedVideos = details.RelatedVideos
+			node.Topics = details.Topics
+		}
+	}
 
 	// Create talk
 	talk := &Talk{
-		URL: url,
+		URL:  url,
+		Slug: slug,
 	}
 
-	// Extract video URLs from subtitledDownloads
+	// Extract video URLs from nativeDownloads and subtitledDownloads
 	talk.VideoURLs = make(map[string]string)
-	node := result.Data.Videos.Nodes[0]
 
-	// Find English version for video URLs
+	// Extract audio-only download
+	talk.ID = node.ID
+	talk.AudioURL = node.AudioDownload
+	setCanonicalURL(talk, node.CanonicalURL)
+
+	// Extract title and speakers
+	talk.Title = strings.TrimSpace(node.Title)
+	talk.Speaker = strings.TrimSpace(node.PresenterDisplayName)
+	for _, speaker := range node.Speakers.Nodes {
+		addSpeaker(talk, Speaker{
+			Name:  speakerName(speaker.Firstname, speaker.Middleinitial, speaker.Lastname),
+			Title: strings.TrimSpace(speaker.Description),
+			Bio:   cleanDescription(speaker.WhoTheyAre),
+		})
+	}
+	if talk.Speaker == "" {
+		talk.Speaker = speakerNames(talk.Speakers)
+	}
+
+	// Extract description, duration, published date and views
+	talk.Description = cleanDescription(node.Description)
+	if node.Duration > 0 {
+		talk.Duration = formatDuration(int(node.Duration))
+	}
+	talk.PublishedDate = formatPublishedDate(node.PublishedAt)
+	if node.ViewedCount > 0 {
+		talk.Views = strconv.FormatInt(node.ViewedCount, 10)
+	}
+
+	// Find English version for video URLs. These have English subtitles
+	// burned in, so they only serve as a fallback for missing native files.
 	for _, sub := range node.SubtitledDownloads {
 		if sub.InternalLanguageCode == "en" {
-			talk.VideoURLs["720p"] = sub.Low
-			talk.VideoURLs["1080p"] = sub.High
+			if sub.Low != "" {
+				talk.VideoURLs["720p"] = sub.Low
+			}
+			if sub.High != "" {
+				talk.VideoURLs["1080p"] = sub.High
+			}
 			break
 		}
 	}
 
-	// Extract subtitle URLs
+	// Prefer the clean nativeDownloads: low -> 360p, medium -> 720p, high -> 1080p
+	native := map[string]string{
+		NormalizeQuality("low"):    node.NativeDownloads.Low,
+		NormalizeQuality("medium"): node.NativeDownloads.Medium,
+		NormalizeQuality("high"):   node.NativeDownloads.High,
+	}
+	for quality, nativeURL := range native {
+		if nativeURL != "" {
+			talk.VideoURLs[quality] = nativeURL
+		}
+	}
+	talk.VideoFormats = videoFormats(talk.VideoURLs)
+
+	// subtitledDownloads are videos with the subtitles burned in; the
+	// subtitle files of the same languages come from the subtitles endpoint
 	talk.SubtitleURLs = make(map[string]string)
+	talk.SubtitledVideoURLs = make(map[string]string)
+	talk.SubtitleLanguages = make(map[string]string)
 	for _, sub := range node.SubtitledDownloads {
+		lang := strings.ToLower(sub.InternalLanguageCode)
+		if lang == "" {
+			continue
+		}
+		if node.ID != "" {
+			talk.SubtitleURLs[lang] = p.subtitleFileURL(node.ID, lang)
+		}
 		if sub.Low != "" {
-			lang := strings.ToLower(sub.InternalLanguageCode)
-			talk.SubtitleURLs[lang] = sub.Low
+			talk.SubtitledVideoURLs[lang] = sub.Low
+		}
+		if sub.LanguageName != "" {
+			talk.SubtitleLanguages[lang] = sub.LanguageName
 		}
 	}
 
-	// Get talk details using regular HTTP client
-	resp, err = http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch talk page: %w", err)
+	// Extract related talks
+	related := make([]string, 0, len(node.RelatedVideos))
+	for _, video := range node.RelatedVideos {
+		related = append(related, video.Slug)
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Println("close response body error:", cerr)
+	addRelatedSlugs(talk, slug, related)
+
+	// Extract topics
+	for _, topic := range node.Topics.Nodes {
+		addTags(talk, []string{topic.Name})
+	}
+
+	// The talk page only fills in details, so the download URLs from
+	// GraphQL are returned even when it can't be fetched
+	if err := p.addPageDetails(ctx, slug, url, talk); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("parsing talk cancelled: %w", ctxErr)
 		}
-	}()
+		p.warnPrint("failed to get talk details from %s, title or speaker may be missing: %v", url, err)
+	}
+
+	p.debugPrint("Successfully parsed talk: %s by %s", talk.Title, talk.Speaker)
+	p.debugPrint("Available subtitles: %v", talk.SubtitleURLs)
+	p.debugPrint("Available subtitled videos: %v", talk.SubtitledVideoURLs)
 
-	// Read and store raw HTML response
-	rawHTML, err := io.ReadAll(resp.Body)
+	return talk, nil
+}
+
+// addPageDetails fills in the title, speaker and any metadata GraphQL did not
+// return from the talk page
+func (p *Parser) addPageDetails(ctx context.Context, slug, url string, talk *Talk) error {
+	rawHTML, err := p.fetchTalkPage(ctx, slug, url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read HTML response: %w", err)
+		return err
 	}
 	p.storeRawResponse("html_"+slug, rawHTML)
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse talk page: %w", err)
+		return fmt.Errorf("failed to parse talk page: %w", err)
 	}
 
-	// Extract title and speaker
-	talk.Title = doc.Find("h1").First().Text()
-	talk.Speaker = doc.Find("h2").First().Text()
-
-	p.debugPrint("Successfully parsed talk: %s by %s", talk.Title, talk.Speaker)
-	p.debugPrint("Available subtitles: %v", talk.SubtitleURLs)
+	// Extract title and speaker unless GraphQL returned them
+	extractTitleAndSpeaker(doc, talk)
 
-	return talk, nil
+	// Fill in any metadata GraphQL did not return
+	p.extractMetadata(doc, talk)
+	return nil
 }
 
-// parseWithHTML attempts to parse using HTML as fallback
-func (p *Parser) parseWithHTML(url string) (*Talk, error) {
-	resp, err := p.client.Get(url)
+// fetchTalkPage returns the HTML of a talk page, from the cache when possible.
+// A cookie consent page is fetched again with consentCookie; interstitial
+// pages are returned as is but never cached.
+func (p *Parser) fetchTalkPage(ctx context.Context, slug, url string) ([]byte, error) {
+	cacheKey := "html_" + slug
+	if lang := p.language(); lang != DefaultLanguage {
+		// Talk pages are localized by Accept-Language
+		cacheKey += "_" + lang
+	}
+	if rawHTML, ok := p.cacheGet(cacheKey); ok {
+		return rawHTML, nil
+	}
+
+	rawHTML, err := p.fetchPage(ctx, url, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch talk page: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Println("close response body error:", cerr)
+	if detectInterstitial(rawHTML) == ErrConsentWall {
+		p.debugPrint("Cookie consent page served for %s, retrying with a consent cookie", url)
+		if retried, err := p.fetchPage(ctx, url, consentCookie); err == nil {
+			rawHTML = retried
 		}
-	}()
+	}
 
-	// Read and store raw HTML response
-	rawHTML, err := io.ReadAll(resp.Body)
+	if detectInterstitial(rawHTML) == nil {
+		p.cacheSet(cacheKey, rawHTML)
+	}
+	return rawHTML, nil
+}
+
+// parseWithHTML attempts to parse using HTML as fallback
+func (p *Parser) parseWithHTML(ctx context.Context, slug, url string) (*Talk, error) {
+	rawHTML, err := p.fetchTalkPage(ctx, slug, url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read HTML response: %w", err)
+		return nil, err
 	}
 	p.storeRawResponse("html_fallback", rawHTML)
 
@@ -476,16 +1211,18 @@ func (p *Parser) parseWithHTML(url string) (*Talk, error) {
 	}
 
 	talk := &Talk{
-		URL: url,
+		URL:  url,
+		Slug: slug,
 	}
 
 	// Extract title and speaker
-	talk.Title = doc.Find("h1").First().Text()
-	talk.Speaker = doc.Find("h2").First().Text()
+	extractTitleAndSpeaker(doc, talk)
+	setCanonicalURL(talk, doc.Find(`link[rel="canonical"]`).AttrOr("href", ""))
 
 	// Try to extract video URLs from page's JSON data
-	if err := p.extractVideoURLs(doc, talk); err != nil {
-		p.debugPrint("Failed to extract video URLs from HTML: %v", err)
+	videoErr := p.extractVideoURLs(doc, talk)
+	if videoErr != nil {
+		p.debugPrint("Failed to extract video URLs from HTML: %v", videoErr)
 	}
 
 	// Try to extract subtitle URLs
@@ -493,11 +1230,22 @@ func (p *Parser) parseWithHTML(url string) (*Talk, error) {
 		p.debugPrint("Failed to extract subtitle URLs from HTML: %v", err)
 	}
 
+	// Extract duration, published date and views
+	p.extractMetadata(doc, talk)
+
 	p.debugPrint("Fallback HTML parsing completed for: %s", talk.Title)
 
-	// If没有视频和字幕，返回 error 和 nil
+	// Video data that can't be decoded means TED changed the page
+	if videoErr != nil && len(talk.VideoURLs) == 0 {
+		return nil, fmt.Errorf("failed to parse talk page %s: %w", url, videoErr)
+	}
+
+	// Without videos or subtitles there is nothing to download
 	if len(talk.VideoURLs) == 0 && len(talk.SubtitleURLs) == 0 {
-		return nil, fmt.Errorf("no video or subtitle data found")
+		if err := detectInterstitial(rawHTML); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, url)
+		}
+		return nil, ErrNoDownloads
 	}
 
 	return talk, nil
@@ -505,16 +1253,21 @@ func (p *Parser) parseWithHTML(url string) (*Talk, error) {
 
 // ParseTalkDetails parses a talk's details page and returns the talk information
 func (p *Parser) ParseTalkDetails(title string) (*Talk, error) {
+	return p.ParseTalkDetailsContext(context.Background(), title)
+}
+
+// ParseTalkDetailsContext is like ParseTalkDetails but aborts when ctx is cancelled
+func (p *Parser) ParseTalkDetailsContext(ctx context.Context, title string) (*Talk, error) {
 	// Search for the talk first
-	talks, err := p.ParseTopic(title, 1)
+	talks, err := p.ParseTopicContext(ctx, title, 1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search for talk: %w", err)
 	}
 
 	if len(talks) == 0 {
-		return nil, fmt.Errorf("no talk found with title: %s", title)
+		return nil, fmt.Errorf("%w with title: %s", ErrTalkNotFound, title)
 	}
 
 	// Parse the talk's details page using URL
-	return p.ParseURL(talks[0].URL)
+	return p.ParseURLContext(ctx, talks[0].URL)
 }
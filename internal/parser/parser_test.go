@@ -1,8 +1,19 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -40,6 +51,10 @@ func TestParseTopic(t *testing.T) {
 				t.Errorf("failed to write: %v", err)
 			}
 
+		case "/graphql":
+			// Make EnrichTalk fall back to the talk page
+			_, _ = w.Write([]byte(`{"errors": [{"message": "Invalid slug"}]}`))
+
 		case "/talks/john_doe_power_of_education", "/talks/jane_smith_learning_digital":
 			// Return mock HTML response for individual talk page
 			html := `
@@ -68,8 +83,8 @@ func TestParseTopic(t *testing.T) {
 			});
 			</script>
 			<div class="talk-subtitles">
-				<a href="/talks/subtitles/en" data-language="en">English</a>
-				<a href="/talks/subtitles/zh" data-language="zh">Chinese</a>
+				<a href="/talks/subtitles/id/399/lang/en.srt" data-language="en">English</a>
+				<a href="/talks/subtitles/id/399/lang/zh.srt" data-language="zh">Chinese</a>
 			</div>`
 			if _, err := w.Write([]byte(html)); err != nil {
 				t.Errorf("failed to write: %v", err)
@@ -86,17 +101,19 @@ func TestParseTopic(t *testing.T) {
 	p.client = &http.Client{
 		Timeout: 5 * time.Second,
 	}
+	p.GraphqlURL = server.URL + "/graphql"
 
-	// Override the base URL for testing
-	originalURL := baseURL
-	baseURL = server.URL
-	defer func() { baseURL = originalURL }()
+	p.BaseURL = server.URL
 
 	// Test parsing
 	talks, err := p.ParseTopic("education", 2)
 	assert.NoError(t, err)
 	assert.Len(t, talks, 2)
 
+	// Video and subtitle URLs are only fetched on request
+	assert.Nil(t, talks[0].VideoURLs)
+	assert.NoError(t, p.EnrichTalk(&talks[0]))
+
 	// Verify first talk
 	assert.Equal(t, "The power of education", talks[0].Title)
 	assert.Equal(t, "John Doe", talks[0].Speaker)
@@ -113,8 +130,8 @@ func TestParseTopic(t *testing.T) {
 
 	// Verify subtitle URLs
 	assert.Len(t, talks[0].SubtitleURLs, 2)
-	assert.Equal(t, server.URL+"/talks/subtitles/en", talks[0].SubtitleURLs["en"])
-	assert.Equal(t, server.URL+"/talks/subtitles/zh", talks[0].SubtitleURLs["zh"])
+	assert.Equal(t, server.URL+"/talks/subtitles/id/399/lang/en.srt", talks[0].SubtitleURLs["en"])
+	assert.Equal(t, server.URL+"/talks/subtitles/id/399/lang/zh.srt", talks[0].SubtitleURLs["zh"])
 
 	// Verify second talk
 	assert.Equal(t, "Learning in the digital age", talks[1].Title)
@@ -131,7 +148,12 @@ func TestParseURL_GraphQL(t *testing.T) {
 					{
 						"id": "399",
 						"canonicalUrl": "https://www.ted.com/talks/test_slug",
+						"description": "A talk about\n  vulnerability — and courage.",
+						"duration": 754,
+						"publishedAt": "2010-12-23T15:10:00Z",
+						"viewedCount": 1234567,
 						"audioDownload": null,
+						"topics": {"nodes": [{"name": "Science"}, {"name": " science"}, {"name": "Technology"}]},
 						"nativeDownloads": {
 							"low": null,
 							"medium": null,
@@ -180,29 +202,70 @@ func TestParseURL_GraphQL(t *testing.T) {
 		RawResponses: make(map[string][]byte),
 	}
 
-	// patch baseURL to mockServer
-	oldBaseURL := baseURL
-	baseURL = mockServer.URL
-	defer func() { baseURL = oldBaseURL }()
-
 	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
 	assert.NoError(t, err)
 	assert.Equal(t, "Test Title", talk.Title)
+	assert.Equal(t, "test_slug", talk.Slug)
+	assert.Equal(t, "399", talk.ID)
 	assert.Equal(t, "Test Speaker", talk.Speaker)
 
 	// Verify video URLs
 	assert.Equal(t, "https://download.ted.com/talks/test-low-en.mp4", talk.VideoURLs["720p"])
 	assert.Equal(t, "https://download.ted.com/talks/test-480p-en.mp4", talk.VideoURLs["1080p"])
 
-	// Verify subtitle URLs
-	assert.Equal(t, "https://download.ted.com/talks/test-low-en.mp4", talk.SubtitleURLs["en"])
-	assert.Equal(t, "https://download.ted.com/talks/test-low-zh-cn.mp4", talk.SubtitleURLs["zh-cn"])
+	// Verify audio is reported as unavailable
+	assert.Empty(t, talk.AudioURL)
+
+	// Verify metadata
+	assert.Equal(t, "A talk about vulnerability — and courage.", talk.Description)
+	assert.Equal(t, "12:34", talk.Duration)
+	assert.Equal(t, "2010-12-23", talk.PublishedDate)
+	assert.Equal(t, "1234567", talk.Views)
+	assert.Equal(t, []string{"science", "technology"}, talk.Tags)
+
+	// Verify subtitle files come from the subtitles endpoint, not the subtitled videos
+	assert.Equal(t, map[string]string{
+		"en":    DefaultBaseURL + "/talks/subtitles/id/399/lang/en.srt",
+		"zh-cn": DefaultBaseURL + "/talks/subtitles/id/399/lang/zh-cn.srt",
+	}, talk.SubtitleURLs)
+	assert.Equal(t, "https://download.ted.com/talks/test-low-en.mp4", talk.SubtitledVideoURLs["en"])
+	assert.Equal(t, "https://download.ted.com/talks/test-low-zh-cn.mp4", talk.SubtitledVideoURLs["zh-cn"])
+	assert.Equal(t, map[string]string{"en": "English", "zh-cn": "Chinese, Simplified"}, talk.SubtitleLanguages)
 
 	// Verify raw responses were stored
 	assert.NotEmpty(t, p.GetRawResponse("graphql_test_slug"))
 	assert.NotEmpty(t, p.GetRawResponse("html_test_slug"))
 }
 
+func TestTalkSubtitleCode(t *testing.T) {
+	// GraphQL codes are stored lowercased, while the README uses zh-CN
+	talk := &Talk{SubtitleURLs: map[string]string{
+		"en":    "https://download.ted.com/talks/test-low-en.mp4",
+		"zh-cn": "https://download.ted.com/talks/test-low-zh-cn.mp4",
+	}}
+
+	tests := []struct {
+		lang string
+		code string
+		ok   bool
+	}{
+		{"zh-CN", "zh-cn", true},
+		{"zh-cn", "zh-cn", true},
+		{"ZH-CN", "zh-cn", true},
+		{"EN", "en", true},
+		{"zh-tw", "", false},
+	}
+	for _, tt := range tests {
+		code, ok := talk.SubtitleCode(tt.lang)
+		assert.Equal(t, tt.ok, ok, tt.lang)
+		assert.Equal(t, tt.code, code, tt.lang)
+
+		url, ok := talk.SubtitleURL(tt.lang)
+		assert.Equal(t, tt.ok, ok, tt.lang)
+		assert.Equal(t, talk.SubtitleURLs[tt.code], url, tt.lang)
+	}
+}
+
 func TestParseURL_GraphQLFallback(t *testing.T) {
 	// mock GraphQL error response
 	graphqlJSON := []byte(`{
@@ -219,12 +282,17 @@ func TestParseURL_GraphQLFallback(t *testing.T) {
 	// mock HTML response with video data
 	html := `
 	<html>
+		<meta property="og:video:tag" content="Society">
 		<h1>Test Title</h1>
 		<h2>Test Speaker</h2>
 		<script>
 		talkPage.init({
 			"playerData": {
 				"talks": [{
+					"tags": ["Culture", "culture", ""],
+					"duration": 3723,
+					"published": 1293117000,
+					"viewed_count": 42,
 					"player_talks": [{
 						"resources": {
 							"h264": [
@@ -246,7 +314,7 @@ func TestParseURL_GraphQLFallback(t *testing.T) {
 		});
 		</script>
 		<div class="talk-subtitles">
-			<a href="/talks/subtitles/en" data-language="en">English</a>
+			<a href="/talks/subtitles/id/399/lang/en.srt" data-language="en">English</a>
 			<a href="/talks/subtitles/zh" data-language="zh">Chinese</a>
 		</div>
 	</html>`
@@ -271,22 +339,28 @@ func TestParseURL_GraphQLFallback(t *testing.T) {
 	}
 
 	// patch baseURL to mockServer
-	oldBaseURL := baseURL
-	baseURL = mockServer.URL
-	defer func() { baseURL = oldBaseURL }()
+	p.BaseURL = mockServer.URL
 
 	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
 	assert.NoError(t, err)
 	assert.Equal(t, "Test Title", talk.Title)
+	assert.Equal(t, "test_slug", talk.Slug)
 	assert.Equal(t, "Test Speaker", talk.Speaker)
 
 	// Verify video URLs from HTML fallback
 	assert.Equal(t, "https://example.com/video/720p.mp4", talk.VideoURLs["720p"])
 	assert.Equal(t, "https://example.com/video/1080p.mp4", talk.VideoURLs["1080p"])
 
+	// Verify metadata from HTML fallback
+	assert.Equal(t, []string{"culture", "society"}, talk.Tags)
+	assert.Equal(t, "1:02:03", talk.Duration)
+	assert.Equal(t, "2010-12-23", talk.PublishedDate)
+	assert.Equal(t, "42", talk.Views)
+
 	// Verify subtitle URLs from HTML fallback
-	assert.Equal(t, mockServer.URL+"/talks/subtitles/en", talk.SubtitleURLs["en"])
-	assert.Equal(t, mockServer.URL+"/talks/subtitles/zh", talk.SubtitleURLs["zh"])
+	// A link to a subtitle page isn't a file to download
+	assert.Equal(t, map[string]string{"en": mockServer.URL + "/talks/subtitles/id/399/lang/en.srt"}, talk.SubtitleURLs)
+	assert.Equal(t, map[string]string{"en": "English", "zh": "Chinese"}, talk.SubtitleLanguages)
 
 	// Verify raw responses were stored
 	assert.NotEmpty(t, p.GetRawResponse("graphql_test_slug"))
@@ -325,14 +399,10 @@ func TestParseURL_NoVideoData(t *testing.T) {
 		RawResponses: make(map[string][]byte),
 	}
 
-	// patch baseURL to mockServer
-	oldBaseURL := baseURL
-	baseURL = mockServer.URL
-	defer func() { baseURL = oldBaseURL }()
-
 	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no video or subtitle data found")
+	assert.True(t, errors.Is(err, ErrNoDownloads))
 	assert.Nil(t, talk)
 }
 
@@ -368,15 +438,11 @@ func TestParseURL_InvalidSlug(t *testing.T) {
 		RawResponses: make(map[string][]byte),
 	}
 
-	// patch baseURL to mockServer
-	oldBaseURL := baseURL
-	baseURL = mockServer.URL
-	defer func() { baseURL = oldBaseURL }()
-
 	// Test with completely invalid URL
 	_, err := p.ParseURL("not-a-ted-url")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid TED talk URL")
+	assert.True(t, errors.Is(err, ErrInvalidURL))
 
 	// Test with URL that has no slug
 	_, err = p.ParseURL("https://www.ted.com/")
@@ -408,11 +474,993 @@ func TestParseURL_GraphQLError(t *testing.T) {
 	defer mockServer.Close()
 
 	p := &Parser{client: mockServer.Client(), GraphqlURL: mockServer.URL + "/graphql"}
-	oldBaseURL := baseURL
-	baseURL = mockServer.URL
-	defer func() { baseURL = oldBaseURL }()
 
+	// The talk page 404s after GraphQL rejected the slug
 	_, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "no video or subtitle data found")
+	assert.True(t, errors.Is(err, ErrTalkNotFound))
+}
+
+func TestParseURL_GraphQLErrorCode(t *testing.T) {
+	graphqlJSON := []byte(`{"errors": [{"message": "Unknown argument", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(graphqlJSON)
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	_, err := p.parseWithGraphQL(context.Background(), "test_slug", mockServer.URL+"/talks/test_slug")
+	var gqlErr *GraphQLError
+	assert.True(t, errors.As(err, &gqlErr))
+	assert.Equal(t, "GRAPHQL_VALIDATION_FAILED", gqlErr.Code)
+	assert.Equal(t, "Unknown argument", gqlErr.Message)
+	assert.Equal(t, "GraphQL error GRAPHQL_VALIDATION_FAILED: Unknown argument", err.Error())
+	assert.True(t, shouldFallback(err))
+}
+
+func TestParseURL_GraphQLRateLimited(t *testing.T) {
+	ok := []byte(`{"data": {"videos": {"nodes": [{"title": "Test Title", "nativeDownloads": {"medium": "https://download.ted.com/talks/test-medium.mp4"}}]}}}`)
+
+	var graphqlCalls, pageCalls int32
+	limitFor := int32(1)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			if atomic.AddInt32(&graphqlCalls, 1) <= atomic.LoadInt32(&limitFor) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write(ok)
+			return
+		}
+		atomic.AddInt32(&pageCalls, 1)
+		_, _ = w.Write([]byte(`<html><h1>Test Title</h1></html>`))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.retryDelay = time.Millisecond
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	// A rate-limited query is retried
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Title", talk.Title)
+	// The talk query is sent twice, then the details query once
+	assert.Equal(t, int32(3), atomic.LoadInt32(&graphqlCalls))
+
+	// Once the retries are exhausted the error is returned without falling back
+	atomic.StoreInt32(&graphqlCalls, 0)
+	atomic.StoreInt32(&pageCalls, 0)
+	atomic.StoreInt32(&limitFor, 100)
+	_, err = p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.False(t, shouldFallback(err))
+	assert.Equal(t, int32(p.MaxRetries), atomic.LoadInt32(&graphqlCalls))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pageCalls))
+}
+
+func TestParseURLContext_Cancelled(t *testing.T) {
+	// mock server that never answers until the test finishes
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer mockServer.Close()
+	defer close(release)
+
+	p := &Parser{
+		client:       mockServer.Client(),
+		GraphqlURL:   mockServer.URL + "/graphql",
+		RawResponses: make(map[string][]byte),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	talk, err := p.ParseURLContext(ctx, mockServer.URL+"/talks/test_slug")
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "cancelled")
+	assert.Nil(t, talk)
+}
+
+func TestParseTopicContext_Cancelled(t *testing.T) {
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer mockServer.Close()
+	defer close(release)
+
+	p := New()
+	p.client = mockServer.Client()
+
+	p.BaseURL = mockServer.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	talks, err := p.ParseTopicContext(ctx, "education", 2)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Nil(t, talks)
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestNewWithClient(t *testing.T) {
+	graphqlJSON := []byte(`{
+		"data": {
+			"videos": {
+				"nodes": [
+					{
+						"audioDownload": "https://download.ted.com/talks/test.mp3",
+						"subtitledDownloads": [
+							{
+								"low": "https://download.ted.com/talks/test-low-en.mp4",
+								"high": "https://download.ted.com/talks/test-480p-en.mp4",
+								"internalLanguageCode": "en",
+								"languageName": "English"
+							}
+						]
+					}
+				]
+			}
+		}
+	}`)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			_, _ = w.Write(graphqlJSON)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><h1>Test Title</h1><h2>Test Speaker</h2></html>`))
+	}))
+	defer mockServer.Close()
+
+	// Count every request that goes through the injected client
+	var paths []string
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			paths = append(paths, r.URL.Path)
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	p := NewWithClient(client)
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Title", talk.Title)
+	assert.Equal(t, "https://download.ted.com/talks/test.mp3", talk.AudioURL)
+	assert.Equal(t, []string{"/graphql", "/graphql", "/talks/test_slug"}, paths)
+}
+
+func TestSetClient(t *testing.T) {
+	p := New()
+	original := p.client
+
+	p.SetClient(nil)
+	assert.Same(t, original, p.client)
+
+	client := &http.Client{Timeout: time.Second}
+	p.SetClient(client)
+	assert.Same(t, client, p.client)
+}
+
+func TestParseURL_RelatedSlugs(t *testing.T) {
+	graphqlJSON := []byte(`{
+		"data": {
+			"videos": {
+				"nodes": [
+					{
+						"nativeDownloads": {"low": "https://download.ted.com/talks/test-low.mp4"},
+						"relatedVideos": [
+							{"slug": "related_1"},
+							{"slug": "test_slug"},
+							{"slug": "related_2"},
+							{"slug": "related_2"},
+							{"slug": "related_3"},
+							{"slug": "related_4"},
+							{"slug": "related_5"},
+							{"slug": "related_6"},
+							{"slug": "related_7"}
+						]
+					}
+				]
+			}
+		}
+	}`)
+
+	mockServer := newMockTEDServer(graphqlJSON, `<html><h1>Test Title</h1><h2>Test Speaker</h2></html>`)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	// Own slug and duplicates are dropped, and the list is capped
+	assert.Equal(t, []string{"related_1", "related_2", "related_3", "related_4", "related_5", "related_6"}, talk.RelatedSlugs)
+
+	p.BaseURL = "https://www.ted.com/"
+	assert.Equal(t, "https://www.ted.com/talks/related_1", p.TalkURL(talk.RelatedSlugs[0]))
+}
+
+func TestParseURL_Speakers(t *testing.T) {
+	graphqlJSON := []byte(`{
+		"data": {
+			"videos": {
+				"nodes": [
+					{
+						"presenterDisplayName": "",
+						"speakers": {
+							"nodes": [
+								{
+									"firstname": "Brené",
+									"middleinitial": "",
+									"lastname": "Brown",
+									"description": "Vulnerability researcher",
+									"whoTheyAre": "Brené Brown studies  vulnerability,\ncourage and empathy."
+								},
+								{
+									"firstname": "Jane",
+									"middleinitial": "Q.",
+									"lastname": "Doe",
+									"description": "",
+									"whoTheyAre": ""
+								}
+							]
+						},
+						"nativeDownloads": {"low": "https://download.ted.com/talks/test-low.mp4"}
+					}
+				]
+			}
+		}
+	}`)
+
+	// The h2 holds unrelated text and must not be used as the speaker
+	mockServer := newMockTEDServer(graphqlJSON, `<html><h1>Test Title</h1><h2>Up next</h2></html>`)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "Brené Brown, Jane Q. Doe", talk.Speaker)
+	assert.Equal(t, []Speaker{
+		{Name: "Brené Brown", Title: "Vulnerability researcher", Bio: "Brené Brown studies vulnerability, courage and empathy."},
+		{Name: "Jane Q. Doe"},
+	}, talk.Speakers)
+}
+
+func TestParseURL_TalkPageFixture(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "talk_page.html"))
+	assert.NoError(t, err)
+
+	// GraphQL returns download URLs but no title or speaker, so they come from the page
+	mockServer := newMockTEDServer(lowVideoGraphQL, string(fixture))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err := p.ParseURL(mockServer.URL + "/talks/brene_brown_the_power_of_vulnerability")
+	assert.NoError(t, err)
+	assert.Equal(t, "The power of vulnerability", talk.Title)
+	assert.Equal(t, "Brené Brown", talk.Speaker)
+	assert.Contains(t, talk.Description, "In a poignant, funny talk")
+}
+
+func TestUserAgent(t *testing.T) {
+	var agents []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.UserAgent())
+		if r.URL.Path == "/graphql" {
+			_, _ = w.Write(lowVideoGraphQL)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><h1>Test Title</h1><h2>Test Speaker</h2></html>`))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	// GraphQL and HTML requests carry the same default User-Agent
+	_, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{DefaultUserAgent, DefaultUserAgent, DefaultUserAgent}, agents)
+
+	agents = nil
+	p.UserAgent = "tedfetch-test/1.0"
+	_, err = p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tedfetch-test/1.0", "tedfetch-test/1.0", "tedfetch-test/1.0"}, agents)
+}
+
+// lowVideoGraphQL is a GraphQL talk response with only a low quality video,
+// leaving the title and speaker to the HTML page
+var lowVideoGraphQL = []byte(`{"data": {"videos": {"nodes": [{"nativeDownloads": {"low": "https://download.ted.com/talks/test-low.mp4"}}]}}}`)
+
+// newMockTEDServer serves graphqlJSON on /graphql and html on every other path
+func newMockTEDServer(graphqlJSON []byte, html string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(graphqlJSON)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(html))
+	}))
+}
+
+func TestParseURL_Language(t *testing.T) {
+	var languages []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			var body struct {
+				Variables struct {
+					Language string `json:"language"`
+				} `json:"variables"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			languages = append(languages, body.Variables.Language)
+			_, _ = w.Write([]byte(`{"data": {"videos": {"nodes": [{"title": "El poder de la vulnerabilidad", "nativeDownloads": {"medium": "https://download.ted.com/talks/test-medium.mp4"}}]}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`<html></html>`))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+	p.Debug = true
+
+	_, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+
+	p.Language = "es"
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "El poder de la vulnerabilidad", talk.Title)
+
+	assert.Equal(t, []string{"en", "en", "es", "es"}, languages)
+	// Responses in other languages are kept apart from the English one
+	assert.NotEmpty(t, p.GetRawResponse("graphql_test_slug_es"))
+	assert.NotEmpty(t, p.GetRawResponse("graphql_details_test_slug_es"))
+}
+
+func TestTalkQuery(t *testing.T) {
+	assert.True(t, strings.HasPrefix(TalkQuery, "query "+TalkOperation+"($slug: String!, $language: String)"))
+	for _, field := range []string{"id", "canonicalUrl", "nativeDownloads", "subtitledDownloads"} {
+		assert.Regexp(t, `\s`+field+`\s`, TalkQuery, field)
+	}
+	assert.True(t, strings.HasPrefix(TalkDetailsQuery, "query "+TalkDetailsOperation+"($slug: String!, $language: String)"))
+	for _, field := range []string{"presenterDisplayName", "speakers", "relatedVideos", "topics"} {
+		assert.NotRegexp(t, `\s`+field+`\s`, TalkQuery, field)
+		assert.Regexp(t, `\s`+field+`\s`, TalkDetailsQuery, field)
+	}
+}
+
+func TestParseURL_DetailsQueryRejected(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			var body struct {
+				OperationName string `json:"operationName"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body.OperationName == TalkDetailsOperation {
+				_, _ = w.Write([]byte(`{"errors": [{"message": "Cannot query field \"relatedVideos\" on type \"Video\".", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`))
+				return
+			}
+			_, _ = w.Write(lowVideoGraphQL)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><h1>Test Title</h1><h2>Test Speaker</h2></html>`))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	// The download URLs come from GraphQL, the speaker from the page
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://download.ted.com/talks/test-low.mp4", talk.VideoURLs["360p"])
+	assert.Equal(t, "Test Title", talk.Title)
+	assert.Equal(t, "Test Speaker", talk.Speaker)
+}
+
+func TestParseURL_GraphQLQueryOverride(t *testing.T) {
+	var operations, queries []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			var body struct {
+				OperationName string `json:"operationName"`
+				Query         string `json:"query"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			operations = append(operations, body.OperationName)
+			queries = append(queries, body.Query)
+			_, _ = w.Write([]byte(`{"data": {"videos": {"nodes": [{"title": "Test Title", "tags": ["science"], "nativeDownloads": {"medium": "https://download.ted.com/talks/test-medium.mp4"}}]}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`<html></html>`))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+	p.AlwaysStoreRaw = true
+
+	_, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+
+	p.GraphQLQuery = strings.Replace(TalkQuery, "query shareLinks(", "query talkWithTags(", 1)
+	p.GraphQLQuery = strings.Replace(p.GraphQLQuery, "\t\t\tid\n", "\t\t\tid\n\t\t\ttags\n", 1)
+	p.GraphQLOperation = "talkWithTags"
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Title", talk.Title)
+
+	// A custom query is sent without the details query
+	assert.Equal(t, []string{TalkOperation, TalkDetailsOperation, "talkWithTags"}, operations)
+	assert.Equal(t, []string{TalkQuery, TalkDetailsQuery, p.GraphQLQuery}, queries)
+	assert.Contains(t, queries[2], "tags")
+	// The response of the custom query is kept apart from the default one
+	assert.Len(t, p.RawResponses, 4)
+	assert.NotEmpty(t, p.GetRawResponse("graphql_test_slug"))
+}
+
+func TestParseTalkDetails_Mirror(t *testing.T) {
+	var paths []string
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/graphql" {
+			_, _ = w.Write([]byte(`{"data": {"videos": {"nodes": [{"title": "Test Title", "nativeDownloads": {"medium": "https://download.ted.com/talks/test-medium.mp4"}}]}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`<html></html>`))
+	}))
+	defer mirror.Close()
+
+	// Without GraphqlURL, the GraphQL endpoint of the mirror is used
+	p := NewWithClient(mirror.Client())
+	p.BaseURL = mirror.URL + "/"
+	p.GraphqlURL = ""
+
+	talk, err := p.ParseURL(p.TalkURL("test_slug"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Title", talk.Title)
+	assert.Equal(t, mirror.URL+"/talks/test_slug", talk.URL)
+	assert.Contains(t, paths, "/graphql")
+}
+
+func TestParseURL_GraphQLNativeDownloads(t *testing.T) {
+	graphqlJSON := []byte(`{
+		"data": {
+			"videos": {
+				"nodes": [
+					{
+						"nativeDownloads": {
+							"low": "https://download.ted.com/talks/test-low.mp4",
+							"medium": "https://download.ted.com/talks/test-medium.mp4",
+							"high": null
+						},
+						"subtitledDownloads": [
+							{
+								"low": "https://download.ted.com/talks/test-low-en.mp4",
+								"high": "https://download.ted.com/talks/test-480p-en.mp4",
+								"internalLanguageCode": "en",
+								"languageName": "English"
+							}
+						]
+					}
+				]
+			}
+		}
+	}`)
+
+	mockServer := newMockTEDServer(graphqlJSON, `<html><h1>Test Title</h1><h2>Test Speaker</h2></html>`)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+
+	// Native files win, the subtitled file only fills the gap
+	assert.Equal(t, map[string]string{
+		"360p":  "https://download.ted.com/talks/test-low.mp4",
+		"720p":  "https://download.ted.com/talks/test-medium.mp4",
+		"1080p": "https://download.ted.com/talks/test-480p-en.mp4",
+	}, talk.VideoURLs)
+}
+
+func TestParseURL_GraphQLSkipsEmptyVideoURLs(t *testing.T) {
+	graphqlJSON := []byte(`{"data": {"videos": {"nodes": [{"subtitledDownloads": [{"low": "https://download.ted.com/talks/test-low-en.mp4", "high": null, "internalLanguageCode": "en"}]}]}}}`)
+	mockServer := newMockTEDServer(graphqlJSON, `<html><h1>Test Title</h1></html>`)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"720p": "https://download.ted.com/talks/test-low-en.mp4"}, talk.VideoURLs)
+}
+
+func TestParseTopic_Pagination(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		pages = append(pages, page)
+		n, _ := strconv.Atoi(page)
+
+		// Three pages of three talks, numbered links on all but the last
+		var html strings.Builder
+		for i := 1; i <= 3 && n <= 3; i++ {
+			fmt.Fprintf(&html, `<div class="media__message"><div class="media__message__title"><a href="/talks/talk_%d_%d">Talk %d.%d</a></div></div>`, n, i, n, i)
+		}
+		if n < 3 {
+			fmt.Fprintf(&html, `<a href="/talks?topics[]=education&page=%d">%d</a>`, n+1, n+1)
+		}
+		_, _ = w.Write([]byte(html.String()))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+
+	// Stops as soon as the limit is reached
+	talks, err := p.ParseTopic("education", 5)
+	assert.NoError(t, err)
+	assert.Len(t, talks, 5)
+	assert.Equal(t, "Talk 2.2", talks[4].Title)
+	assert.Equal(t, []string{"1", "2"}, pages)
+
+	// Follows pages until they run out
+	pages = nil
+	talks, err = p.ParseTopic("education", 50)
+	assert.NoError(t, err)
+	assert.Len(t, talks, 9)
+	assert.Equal(t, []string{"1", "2", "3"}, pages)
+}
+
+func TestParseTopic_NotFound(t *testing.T) {
+	missing := "1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		if page == missing {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`<div class="media__message"><div class="media__message__title"><a href="/talks/talk_1">Talk 1</a></div></div>
+			<a href="/talks?topics[]=education&page=2">2</a>`))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+	p.MaxRetries = 1
+
+	// A missing topic fails instead of listing no talks
+	_, err := p.ParseTopic("education", 5)
+	assert.ErrorIs(t, err, ErrPageNotFound)
+
+	// A missing page past the first ends the results
+	missing = "2"
+	talks, err := p.ParseTopic("education", 5)
+	assert.NoError(t, err)
+	assert.Len(t, talks, 1)
+}
+
+func TestNormalizeQuality(t *testing.T) {
+	tests := map[string]string{
+		"720p":   "720p",
+		"720P":   "720p",
+		"1080":   "1080p",
+		" 480p ": "480p",
+		"low":    "360p",
+		"medium": "720p",
+		"High":   "1080p",
+		"64k":    "64k",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, NormalizeQuality(in), in)
+	}
+}
+
+func TestParseURL_HTMLQualityKeys(t *testing.T) {
+	graphqlError := []byte(`{"errors": [{"message": "Invalid slug", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`)
+	html := `<html><script>talkPage.init({"playerData": {"talks": [{"player_talks": [{"resources": {"h264": [
+		{"quality": "low", "size": 100, "file": "https://example.com/low.mp4"},
+		{"quality": "medium", "size": 200, "file": "https://example.com/medium.mp4"},
+		{"quality": "1080P", "size": 300, "file": "https://example.com/1080p.mp4"}
+	]}}]}]}})</script></html>`
+	mockServer := newMockTEDServer(graphqlError, html)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	// The same keys as the GraphQL path
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"360p":  "https://example.com/low.mp4",
+		"720p":  "https://example.com/medium.mp4",
+		"1080p": "https://example.com/1080p.mp4",
+	}, talk.VideoURLs)
+	assert.Equal(t, "720p", talk.VideoFormats[1].Quality)
+}
+
+func TestParseURL_HTMLUnexpectedFormat(t *testing.T) {
+	graphqlError := []byte(`{"errors": [{"message": "Invalid slug", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`)
+	html := `<html><script>talkPage.init({"playerData": {"talks": "not a list"}})</script>
+		<a data-language="en" href="/talks/test_slug/transcript.srt">English</a></html>`
+	mockServer := newMockTEDServer(graphqlError, html)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	// Video data that doesn't decode is reported, not mistaken for a talk without videos
+	_, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.ErrorIs(t, err, ErrUnexpectedFormat)
+	assert.Contains(t, err.Error(), "talkPage.init")
+
+	// A page without video data is fine
+	mockServer = newMockTEDServer(graphqlError, `<html><a data-language="en" href="/talks/test_slug/transcript.srt">English</a></html>`)
+	defer mockServer.Close()
+	p = NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err := p.ParseURL(mockServer.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Empty(t, talk.VideoURLs)
+	assert.Len(t, talk.SubtitleURLs, 1)
+}
+
+func TestIsVideoURL(t *testing.T) {
+	assert.True(t, isVideoURL("https://download.ted.com/talks/test-low-en.mp4"))
+	assert.True(t, isVideoURL("https://download.ted.com/talks/test-low-en.MP4?apikey=x"))
+	assert.False(t, isVideoURL("https://www.ted.com/talks/test_slug/transcript.srt"))
+	assert.False(t, isVideoURL("/talks/subtitles/en"))
+}
+
+func TestIsSubtitleFileURL(t *testing.T) {
+	assert.True(t, isSubtitleFileURL("https://www.ted.com/talks/subtitles/id/399/lang/en.srt"))
+	assert.True(t, isSubtitleFileURL("https://www.ted.com/talks/test_slug/transcript.VTT?lang=en"))
+	assert.False(t, isSubtitleFileURL("https://www.ted.com/talks/subtitles/en"))
+	assert.False(t, isSubtitleFileURL("https://download.ted.com/talks/test-low-en.mp4"))
+}
+
+func TestParseTopic_ListOnly(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		html := `
+		<div class="media__message">
+			<span class="thumb__duration">12:34</span>
+			<div class="media__message__title">
+				<h4><a href="/talks/john_doe_power_of_education">The power of education</a></h4>
+			</div>
+			<div class="media__message__speaker">
+				<h4>John Doe</h4>
+			</div>
+		</div>`
+		_, _ = w.Write([]byte(html))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+
+	p.BaseURL = server.URL
+
+	talks, err := p.ParseTopic("education", 5)
+	assert.NoError(t, err)
+	assert.Len(t, talks, 1)
+	assert.Equal(t, "The power of education", talks[0].Title)
+	assert.Equal(t, "John Doe", talks[0].Speaker)
+	assert.Equal(t, "12:34", talks[0].Duration)
+	assert.Equal(t, server.URL+"/talks/john_doe_power_of_education", talks[0].URL)
+	assert.Nil(t, talks[0].VideoURLs)
+
+	// Only the list page is fetched, never the individual talk page
+	assert.Equal(t, []string{"/talks"}, paths)
+}
+
+func TestParseTopic_Limit(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		html := `
+		<div class="media__message">
+			<div class="media__message__title">
+				<h4><a href="/talks/john_doe_power_of_education">The power of education</a></h4>
+			</div>
+		</div>
+		<div class="media__message">
+			<div class="media__message__title">
+				<h4><a href="/talks/jane_smith_learning_digital">Learning in the digital age</a></h4>
+			</div>
+		</div>`
+		_, _ = w.Write([]byte(html))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+	var logs bytes.Buffer
+	p.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	// Limits below 1 are rejected before any request
+	for _, limit := range []int{0, -1} {
+		talks, err := p.ParseTopic("education", limit)
+		assert.ErrorIs(t, err, ErrInvalidLimit, limit)
+		assert.Nil(t, talks)
+	}
+	assert.Zero(t, requests)
+
+	// A limit above the available results returns them all
+	talks, err := p.ParseTopic("education", 100)
+	assert.NoError(t, err)
+	assert.Len(t, talks, 2)
+	assert.Empty(t, logs.String())
+
+	// A huge limit still works, with a warning
+	talks, err = p.ParseTopic("education", largeListLimit+1)
+	assert.NoError(t, err)
+	assert.Len(t, talks, 2)
+	assert.Contains(t, logs.String(), "level=WARN")
+	assert.Contains(t, logs.String(), "large limit")
+}
+
+func TestParseTopic_AcceptLanguage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Echo the header as the title, like a localized list would
+		html := `
+		<div class="media__message">
+			<div class="media__message__title">
+				<h4><a href="/talks/john_doe_power_of_education">` + r.Header.Get("Accept-Language") + `</a></h4>
+			</div>
+		</div>`
+		_, _ = w.Write([]byte(html))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+
+	talks, err := p.ParseTopic("education", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "en", talks[0].Title)
+
+	p.Language = "es"
+	talks, err = p.ParseTopic("education", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "es,en;q=0.8", talks[0].Title)
+}
+
+func TestParseTopic_Duplicates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		html := `
+		<div class="search__result">
+			<h3><a href="/talks/john_doe_power_of_education">The power of education</a></h3>
+			<div class="search__result__speaker">John Doe</div>
+		</div>
+		<div class="search__result">
+			<h3><a href="/talks/jane_smith_learning_digital">Learning in the digital age</a></h3>
+			<div class="search__result__speaker">Jane Smith</div>
+		</div>
+		<div class="search__result">
+			<h3><a href="https://www.ted.com/talks/john_doe_power_of_education?language=en">The power of education</a></h3>
+			<div class="search__result__speaker">John Doe</div>
+		</div>`
+		_, _ = w.Write([]byte(html))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+
+	talks, err := p.ParseTopic("power of education", 10)
+	assert.NoError(t, err)
+	var slugs []string
+	for _, talk := range talks {
+		slugs = append(slugs, talk.Slug)
+	}
+	assert.Equal(t, []string{"john_doe_power_of_education", "jane_smith_learning_digital"}, slugs)
+	assert.Equal(t, server.URL+"/talks/john_doe_power_of_education", talks[0].URL)
+}
+
+func TestParseURL_GraphQLPageUnavailable(t *testing.T) {
+	graphqlJSON := []byte(`{"data": {"videos": {"nodes": [{"title": "Test Title", "presenterDisplayName": "Test Speaker", "nativeDownloads": {"medium": "https://download.ted.com/talks/test-medium.mp4"}}]}}}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			_, _ = w.Write(graphqlJSON)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.GraphqlURL = server.URL + "/graphql"
+	p.MaxRetries = 1
+	var logs bytes.Buffer
+	p.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	// The download URLs from GraphQL survive a failing talk page
+	talk, err := p.ParseURL(server.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Contains(t, logs.String(), "level=WARN")
+	assert.Contains(t, logs.String(), "failed to get talk details")
+	assert.Equal(t, "Test Title", talk.Title)
+	assert.Equal(t, "Test Speaker", talk.Speaker)
+	assert.Equal(t, map[string]string{"720p": "https://download.ted.com/talks/test-medium.mp4"}, talk.VideoURLs)
+}
+
+func TestSlugFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.ted.com/talks/test_slug", "test_slug"},
+		{"https://www.ted.com/talks/test_slug/", "test_slug"},
+		{"https://www.ted.com/talks/test_slug?language=fr", "test_slug"},
+		{"https://www.ted.com/talks/test_slug?subtitle=on", "test_slug"},
+		{"https://www.ted.com/talks/test_slug#t-60000", "test_slug"},
+		{"https://www.ted.com/talks/test_slug/transcript", "test_slug"},
+		{"https://www.ted.com/talks/test_slug/transcript?language=zh-cn", "test_slug"},
+		{"https://www.ted.com/talks/test_slug/details/", "test_slug"},
+		{"https://www.ted.com/talks/test_slug/up-next", "test_slug"},
+		// A talk whose slug happens to be a subpage name
+		{"https://www.ted.com/talks/transcript", "transcript"},
+		{"https://www.ted.com/", ""},
+		{"https://www.ted.com/playlists/171/test", ""},
+	}
+	for _, tt := range tests {
+		slug, err := SlugFromURL(tt.url)
+		assert.Equal(t, tt.want, slug, tt.url)
+		if tt.want == "" {
+			assert.ErrorIs(t, err, ErrInvalidURL, tt.url)
+		} else {
+			assert.NoError(t, err, tt.url)
+		}
+	}
+}
+
+func TestTalkPageURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.ted.com/talks/test_slug", "https://www.ted.com/talks/test_slug"},
+		{"https://www.ted.com/talks/test_slug#t-60000", "https://www.ted.com/talks/test_slug"},
+		{"https://www.ted.com/talks/test_slug/transcript", "https://www.ted.com/talks/test_slug"},
+		{"https://www.ted.com/talks/test_slug/transcript?language=zh-cn", "https://www.ted.com/talks/test_slug?language=zh-cn"},
+		{"https://www.ted.com/talks/test_slug/transcript#t-60000", "https://www.ted.com/talks/test_slug"},
+		{"https://www.ted.com/talks/test_slug/transcript/?language=fr#t-60000", "https://www.ted.com/talks/test_slug?language=fr"},
+		{"https://www.ted.com/talks/test_slug/up-next/", "https://www.ted.com/talks/test_slug"},
+		{"https://www.ted.com/talks/transcript", "https://www.ted.com/talks/transcript"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, talkPageURL(tt.url), tt.url)
+	}
+}
+
+func TestParseURL_Subpage(t *testing.T) {
+	var slugs, pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			var body struct {
+				Variables struct {
+					Slug string `json:"slug"`
+				} `json:"variables"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			slugs = append(slugs, body.Variables.Slug)
+			_, _ = w.Write([]byte(`{"errors": [{"message": "Invalid slug", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`))
+			return
+		}
+		pages = append(pages, r.URL.String())
+		_, _ = w.Write([]byte(`<html><h1>Test Title</h1><a data-language="en" href="/talks/test_slug/transcript.srt">English</a></html>`))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.GraphqlURL = server.URL + "/graphql"
+
+	// The talk page is fetched, not the transcript, and the fragment is dropped
+	talk, err := p.ParseURL(server.URL + "/talks/test_slug/transcript?language=zh-cn#t-60000")
+	assert.NoError(t, err)
+	assert.Equal(t, "test_slug", talk.Slug)
+	assert.Equal(t, server.URL+"/talks/test_slug?language=zh-cn", talk.URL)
+	assert.NotEmpty(t, slugs)
+	for _, slug := range slugs {
+		assert.Equal(t, "test_slug", slug)
+	}
+	assert.Equal(t, []string{"/talks/test_slug?language=zh-cn"}, pages)
+}
+
+func TestParseURL_GraphQLVideoFormats(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/graphql":
+			fmt.Fprintf(w, `{"data": {"videos": {"nodes": [{"title": "Test Title", "nativeDownloads": {"low": "%[1]s/low.mp4", "high": "%[1]s/high.mp4"}}]}}}`, server.URL)
+		default:
+			_, _ = w.Write([]byte(`<html><h1>Test Title</h1></html>`))
+		}
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.GraphqlURL = server.URL + "/graphql"
+
+	// Listed from low to high, without sizes
+	talk, err := p.ParseURL(server.URL + "/talks/test_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, []VideoFormat{
+		{Quality: "360p", URL: server.URL + "/low.mp4"},
+		{Quality: "1080p", URL: server.URL + "/high.mp4"},
+	}, talk.VideoFormats)
+}
+
+func TestParseURL_CanonicalURL(t *testing.T) {
+	graphqlJSON := []byte(`{"data": {"videos": {"nodes": [{"title": "Test Title", "canonicalUrl": "https://www.ted.com/talks/new_slug", "nativeDownloads": {"medium": "https://download.ted.com/talks/test-medium.mp4"}}]}}}`)
+	mockServer := newMockTEDServer(graphqlJSON, `<html><h1>Test Title</h1></html>`)
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	// Downloads are named after the canonical slug, not the one pasted
+	talk, err := p.ParseURL(mockServer.URL + "/talks/old_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, mockServer.URL+"/talks/old_slug", talk.URL)
+	assert.Equal(t, "https://www.ted.com/talks/new_slug", talk.CanonicalURL)
+	assert.Equal(t, "new_slug", talk.Slug)
+
+	// The talk page's canonical link does the same on the HTML path
+	graphqlError := []byte(`{"errors": [{"message": "Invalid slug", "extensions": {"code": "GRAPHQL_VALIDATION_FAILED"}}]}`)
+	mockServer = newMockTEDServer(graphqlError, `<html><head><link rel="canonical" href="https://www.ted.com/talks/new_slug"></head>
+		<a data-language="en" href="/talks/new_slug/transcript.srt">English</a></html>`)
+	defer mockServer.Close()
+	p = NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	talk, err = p.ParseURL(mockServer.URL + "/talks/old_slug")
+	assert.NoError(t, err)
+	assert.Equal(t, "new_slug", talk.Slug)
 }
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/baiyutang/tedfetch/internal/logging"
+)
+
+// maxPlaylistPages bounds how many pages of a playlist are followed
+const maxPlaylistPages = 50
+
+// Playlist represents a curated TED playlist
+type Playlist struct {
+	Title string
+	URL   string
+	Talks []Talk // Lightweight talks, see EnrichTalk
+}
+
+// IsPlaylistURL reports whether url points to a TED playlist
+func IsPlaylistURL(url string) bool {
+	return strings.Contains(strings.SplitN(url, "?", 2)[0], "/playlists/")
+}
+
+// ParsePlaylist fetches a TED playlist and every talk in it, following
+// pagination. Talks are returned without video or subtitle URLs.
+func (p *Parser) ParsePlaylist(url string) (*Playlist, error) {
+	return p.ParsePlaylistContext(context.Background(), url)
+}
+
+// ParsePlaylistContext is like ParsePlaylist but aborts when ctx is cancelled
+func (p *Parser) ParsePlaylistContext(ctx context.Context, url string) (*Playlist, error) {
+	if !IsPlaylistURL(url) {
+		return nil, fmt.Errorf("invalid TED playlist URL")
+	}
+
+	playlist := &Playlist{URL: url}
+	seen := make(map[string]bool)
+	pageURL := url
+	for page := 1; page <= maxPlaylistPages && pageURL != ""; page++ {
+		p.debugPrint("Fetching playlist page %d: %s", page, pageURL)
+		doc, err := p.fetchDocument(ctx, pageURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch playlist page: %w", err)
+		}
+
+		if playlist.Title == "" {
+			playlist.Title = strings.TrimSpace(doc.Find("h1").First().Text())
+		}
+
+		added := 0
+		doc.Find(`a[href*="/talks/"]`).Each(func(i int, s *goquery.Selection) {
+			href, _ := s.Attr("href")
+			if !strings.HasPrefix(href, "http") {
+				href = p.baseURL() + href
+			}
+			slug, err := SlugFromURL(href)
+			if err != nil || seen[slug] {
+				return
+			}
+			seen[slug] = true
+			added++
+			playlist.Talks = append(playlist.Talks, Talk{
+				Title: strings.TrimSpace(s.Text()),
+				URL:   href,
+				Slug:  slug,
+			})
+		})
+
+		// Stop when a page adds nothing new, in case "next" loops back
+		if added == 0 {
+			break
+		}
+
+		pageURL = ""
+		if next, ok := doc.Find(`a[rel="next"], a.pagination__next`).First().Attr("href"); ok && next != "" {
+			if !strings.HasPrefix(next, "http") {
+				next = p.baseURL() + next
+			}
+			pageURL = next
+		}
+	}
+
+	if len(playlist.Talks) == 0 {
+		return nil, fmt.Errorf("no talks found in playlist")
+	}
+
+	return playlist, nil
+}
+
+// fetchDocument fetches url and parses it as HTML. A missing page is
+// reported as ErrPageNotFound rather than parsed as an empty one.
+func (p *Parser) fetchDocument(ctx context.Context, url string) (*goquery.Document, error) {
+	resp, err := p.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer logging.CloseBody(p.log(), resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrPageNotFound, url)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlaylist(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.RawQuery)
+		switch r.URL.Query().Get("page") {
+		case "":
+			_, _ = w.Write([]byte(`
+			<h1> The most popular talks of all time </h1>
+			<a href="/talks/brene_brown_the_power_of_vulnerability">The power of vulnerability</a>
+			<a href="/talks/ken_robinson_do_schools_kill_creativity">Do schools kill creativity?</a>
+			<a href="/talks/brene_brown_the_power_of_vulnerability?language=en">The power of vulnerability</a>
+			<a rel="next" href="/playlists/171/the_most_popular_talks_of_all?page=2">Next</a>`))
+		case "2":
+			_, _ = w.Write([]byte(`
+			<h1>The most popular talks of all time</h1>
+			<a href="/talks/amy_cuddy_your_body_language_may_shape_who_you_are">Your body language may shape who you are</a>
+			<a rel="next" href="/playlists/171/the_most_popular_talks_of_all?page=3">Next</a>`))
+		default:
+			// Last page repeats earlier talks, which must end pagination
+			_, _ = w.Write([]byte(`
+			<a href="/talks/ken_robinson_do_schools_kill_creativity">Do schools kill creativity?</a>
+			<a rel="next" href="/playlists/171/the_most_popular_talks_of_all?page=4">Next</a>`))
+		}
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+	playlist, err := p.ParsePlaylist(server.URL + "/playlists/171/the_most_popular_talks_of_all")
+	assert.NoError(t, err)
+	assert.Equal(t, "The most popular talks of all time", playlist.Title)
+	assert.Equal(t, []string{"", "page=2", "page=3"}, pages)
+
+	assert.Len(t, playlist.Talks, 3)
+	assert.Equal(t, "The power of vulnerability", playlist.Talks[0].Title)
+	assert.Equal(t, server.URL+"/talks/brene_brown_the_power_of_vulnerability", playlist.Talks[0].URL)
+	assert.Equal(t, server.URL+"/talks/ken_robinson_do_schools_kill_creativity", playlist.Talks[1].URL)
+	assert.Equal(t, server.URL+"/talks/amy_cuddy_your_body_language_may_shape_who_you_are", playlist.Talks[2].URL)
+}
+
+func TestParsePlaylist_Invalid(t *testing.T) {
+	p := New()
+	_, err := p.ParsePlaylist("https://www.ted.com/talks/test_slug")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid TED playlist URL")
+
+	assert.True(t, IsPlaylistURL("https://www.ted.com/playlists/171/the_most_popular_talks_of_all"))
+	assert.False(t, IsPlaylistURL("https://www.ted.com/talks/test_slug?from=/playlists/171"))
+}
+
+func TestParsePlaylist_NotFound(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("<html><h1>Not found</h1></html>"))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+	p.MaxRetries = 1
+
+	// A missing playlist is an error, not a playlist without talks
+	_, err := p.ParsePlaylist(server.URL + "/playlists/171/missing")
+	assert.ErrorIs(t, err, ErrPageNotFound)
+	_, err = p.ListTopics()
+	assert.ErrorIs(t, err, ErrPageNotFound)
+
+	status = http.StatusForbidden
+	_, err = p.ParsePlaylist(server.URL + "/playlists/171/missing")
+	assert.ErrorContains(t, err, "bad status: 403 Forbidden")
+}
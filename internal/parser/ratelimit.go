@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/baiyutang/tedfetch/internal/retry"
+)
+
+// rateLimiter spaces out requests by a minimum interval. It is a token
+// bucket holding a single token, refilled every interval, shared by all
+// goroutines using the parser.
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time // when the next token is available
+}
+
+// wait blocks until a request may be sent, interval after the previous one,
+// or until ctx is done
+func (l *rateLimiter) wait(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return ctx.Err()
+	}
+
+	// Reserve the next slot before sleeping, so concurrent callers queue up
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(interval)
+	l.mu.Unlock()
+
+	return retry.Sleep(ctx, time.Until(slot))
+}
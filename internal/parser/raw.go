@@ -0,0 +1,69 @@
+package parser
+
+// maxRawResponses bounds how many raw responses AlwaysStoreRaw keeps
+const maxRawResponses = 16
+
+// storeRawResponse stores raw response for debugging. Without Debug, only
+// the most recent maxRawResponses are kept when AlwaysStoreRaw is set.
+// Least recently used responses are evicted once MaxRawResponseBytes is
+// exceeded, but the newest one is always kept.
+func (p *Parser) storeRawResponse(key string, data []byte) {
+	if !p.Debug && !p.AlwaysStoreRaw {
+		return
+	}
+	if p.RawResponses == nil {
+		p.RawResponses = make(map[string][]byte)
+	}
+
+	if old, ok := p.RawResponses[key]; ok {
+		p.forgetRawKey(key)
+		p.rawBytes -= int64(len(old))
+	}
+	p.RawResponses[key] = data
+	p.rawOrder = append(p.rawOrder, key)
+	p.rawLast = key
+	p.rawBytes += int64(len(data))
+
+	for len(p.rawOrder) > 1 {
+		tooMany := !p.Debug && len(p.rawOrder) > maxRawResponses
+		tooBig := p.MaxRawResponseBytes > 0 && p.rawBytes > p.MaxRawResponseBytes
+		if !tooMany && !tooBig {
+			break
+		}
+		oldest := p.rawOrder[0]
+		p.rawBytes -= int64(len(p.RawResponses[oldest]))
+		delete(p.RawResponses, oldest)
+		p.rawOrder = p.rawOrder[1:]
+	}
+}
+
+// forgetRawKey removes key from the storage order
+func (p *Parser) forgetRawKey(key string) {
+	for i, k := range p.rawOrder {
+		if k == key {
+			p.rawOrder = append(p.rawOrder[:i], p.rawOrder[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetRawResponse returns stored raw response and marks it as recently used,
+// which protects it from eviction but doesn't change LastRawResponse
+func (p *Parser) GetRawResponse(key string) []byte {
+	data, ok := p.RawResponses[key]
+	if ok {
+		p.forgetRawKey(key)
+		p.rawOrder = append(p.rawOrder, key)
+	}
+	return data
+}
+
+// LastRawResponse returns the key and body of the most recently stored raw
+// response, or "" and nil if none was stored
+func (p *Parser) LastRawResponse() (string, []byte) {
+	data, ok := p.RawResponses[p.rawLast]
+	if !ok {
+		return "", nil
+	}
+	return p.rawLast, data
+}
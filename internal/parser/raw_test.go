@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreRawResponse(t *testing.T) {
+	// Nothing is kept by default
+	p := New()
+	p.storeRawResponse("graphql_a", []byte("a"))
+	assert.Nil(t, p.GetRawResponse("graphql_a"))
+	key, data := p.LastRawResponse()
+	assert.Equal(t, "", key)
+	assert.Nil(t, data)
+
+	// AlwaysStoreRaw keeps the most recent responses
+	p.AlwaysStoreRaw = true
+	for i := 0; i < maxRawResponses+4; i++ {
+		p.storeRawResponse(fmt.Sprintf("html_%d", i), []byte{byte(i)})
+	}
+	assert.Len(t, p.RawResponses, maxRawResponses)
+	assert.Nil(t, p.GetRawResponse("html_0"))
+	assert.Equal(t, []byte{byte(maxRawResponses + 3)}, p.GetRawResponse(fmt.Sprintf("html_%d", maxRawResponses+3)))
+
+	// Storing a key again makes it the most recent
+	p.storeRawResponse("html_4", []byte("again"))
+	key, data = p.LastRawResponse()
+	assert.Equal(t, "html_4", key)
+	assert.Equal(t, []byte("again"), data)
+	assert.Len(t, p.RawResponses, maxRawResponses)
+
+	// Reading a response doesn't make it the last one
+	assert.Equal(t, []byte{5}, p.GetRawResponse("html_5"))
+	key, data = p.LastRawResponse()
+	assert.Equal(t, "html_4", key)
+	assert.Equal(t, []byte("again"), data)
+}
+
+func TestStoreRawResponse_MaxBytes(t *testing.T) {
+	p := New()
+	p.Debug = true
+	p.MaxRawResponseBytes = 10
+
+	p.storeRawResponse("a", []byte("aaaa"))
+	p.storeRawResponse("b", []byte("bbbb"))
+	// Reading a makes b the least recently used
+	assert.Equal(t, []byte("aaaa"), p.GetRawResponse("a"))
+	p.storeRawResponse("c", []byte("cccc"))
+
+	assert.Nil(t, p.GetRawResponse("b"))
+	assert.Equal(t, []byte("aaaa"), p.GetRawResponse("a"))
+	assert.Equal(t, []byte("cccc"), p.GetRawResponse("c"))
+
+	// A response larger than the limit replaces everything else
+	p.storeRawResponse("big", []byte("0123456789abc"))
+	assert.Len(t, p.RawResponses, 1)
+	key, _ := p.LastRawResponse()
+	assert.Equal(t, "big", key)
+
+	// Debug mode isn't bounded by count
+	p.MaxRawResponseBytes = 0
+	for i := 0; i < maxRawResponses*2; i++ {
+		p.storeRawResponse(fmt.Sprintf("html_%d", i), []byte("x"))
+	}
+	assert.Len(t, p.RawResponses, maxRawResponses*2+1)
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.retryDelay = time.Millisecond
+	resp, err := p.fetch(context.Background(), mockServer.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestDo_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.retryDelay = time.Millisecond
+	p.MaxRetries = 2
+	_, err := p.fetch(context.Background(), mockServer.URL)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "429")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestDo_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	resp, err := p.fetch(context.Background(), mockServer.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDo_RetriesGraphQLPost(t *testing.T) {
+	var calls int32
+	var bodies []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.retryDelay = time.Millisecond
+	p.GraphqlURL = mockServer.URL
+	_, err := p.postGraphQL(context.Background(), "test", "query {}", nil, mockServer.URL)
+	assert.NoError(t, err)
+	assert.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1])
+}
+
+func TestDo_RequestDelay(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.RequestDelay = 20 * time.Millisecond
+
+	// The delay is shared by concurrent requests
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := p.fetch(context.Background(), mockServer.URL)
+			if assert.NoError(t, err) {
+				_ = resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, times, 4)
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	assert.GreaterOrEqual(t, times[3].Sub(times[0]), 2*p.RequestDelay)
+}
+
+func TestDo_RequestDelayCancelled(t *testing.T) {
+	p := New()
+	p.RequestDelay = time.Hour
+	assert.NoError(t, p.limiter.wait(context.Background(), p.RequestDelay))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := p.fetch(ctx, "http://127.0.0.1:0")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SearchBySpeaker returns up to limit talks given by the speaker name. Unlike
+// ParseTopic, it always searches and keeps only the talks whose speaker
+// matches name, ignoring case.
+func (p *Parser) SearchBySpeaker(name string, limit int) ([]Talk, error) {
+	return p.SearchBySpeakerContext(context.Background(), name, limit)
+}
+
+// SearchBySpeakerContext is like SearchBySpeaker but aborts when ctx is cancelled
+func (p *Parser) SearchBySpeakerContext(ctx context.Context, name string, limit int) ([]Talk, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return nil, fmt.Errorf("speaker name is empty")
+	}
+
+	searchURL := fmt.Sprintf("%s/search?cat=talks&q=%s", p.baseURL(), url.QueryEscape(name))
+	return p.parseTalksList(ctx, searchURL, limit, func(talk Talk) bool {
+		return speakerMatches(talk.Speaker, name)
+	})
+}
+
+// speakerMatches reports whether the speaker line of a talk names name
+func speakerMatches(speaker, name string) bool {
+	speaker = strings.ToLower(strings.Join(strings.Fields(speaker), " "))
+	return strings.Contains(speaker, strings.ToLower(name))
+}
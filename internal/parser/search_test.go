@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchBySpeaker(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/search", r.URL.Path)
+		assert.Equal(t, "talks", r.URL.Query().Get("cat"))
+		queries = append(queries, r.URL.Query().Get("q"))
+		_, _ = w.Write([]byte(`
+		<div class="search__result"><h3><a href="/talks/brene_brown_the_power_of_vulnerability">The power of vulnerability</a></h3><div class="search__result__speaker">Brené Brown</div></div>
+		<div class="search__result"><h3><a href="/talks/someone_on_brene_brown">A talk about Brené Brown</a></h3><div class="search__result__speaker">Someone Else</div></div>
+		<div class="search__result"><h3><a href="/talks/brene_brown_listening_to_shame">Listening to shame</a></h3><div class="search__result__speaker">BRENÉ BROWN</div></div>`))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+
+	talks, err := p.SearchBySpeaker("  Brené   Brown ", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, talks, 2) {
+		assert.Equal(t, "The power of vulnerability", talks[0].Title)
+		assert.Equal(t, "Listening to shame", talks[1].Title)
+	}
+	assert.Equal(t, []string{"Brené Brown"}, queries)
+
+	// A single-word name is searched too, not treated as a topic
+	queries = nil
+	talks, err = p.SearchBySpeaker("Brown", 1)
+	assert.NoError(t, err)
+	assert.Len(t, talks, 1)
+	assert.Equal(t, []string{"Brown"}, queries)
+
+	_, err = p.SearchBySpeaker(" ", 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// videoFormats lists videoURLs as VideoFormats from the lowest to the
+// highest resolution, the order of the talk page's JSON. GraphQL doesn't
+// report sizes; Downloader.FillVideoSizes looks them up.
+func videoFormats(videoURLs map[string]string) []VideoFormat {
+	formats := make([]VideoFormat, 0, len(videoURLs))
+	for quality, url := range videoURLs {
+		formats = append(formats, VideoFormat{Quality: quality, URL: url})
+	}
+	sort.Slice(formats, func(i, j int) bool {
+		hi, hj := QualityHeight(formats[i].Quality), QualityHeight(formats[j].Quality)
+		if hi != hj {
+			return hi < hj
+		}
+		return formats[i].Quality < formats[j].Quality
+	})
+	return formats
+}
+
+// QualityHeight returns the vertical resolution of a quality like "720p", or 0 if unknown
+func QualityHeight(quality string) int {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(quality), "p"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
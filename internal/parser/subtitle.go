@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/baiyutang/tedfetch/internal/logging"
+)
+
+// subtitleCue is one caption of TED's subtitles endpoint
+type subtitleCue struct {
+	Content   string `json:"content"`
+	StartTime int64  `json:"startTime"` // milliseconds
+	Duration  int64  `json:"duration"`  // milliseconds
+}
+
+// GetSubtitle fetches the subtitles of the video with the numeric id (see
+// Talk.ID) in lang from TED's subtitles endpoint and returns them as SRT
+func (p *Parser) GetSubtitle(videoID, lang string) ([]byte, error) {
+	return p.GetSubtitleContext(context.Background(), videoID, lang)
+}
+
+// GetSubtitleContext is like GetSubtitle but aborts when ctx is cancelled.
+// If the video has no subtitles in lang, it returns ErrSubtitleNotFound.
+func (p *Parser) GetSubtitleContext(ctx context.Context, videoID, lang string) ([]byte, error) {
+	url := fmt.Sprintf("%s/talks/subtitles/id/%s/lang/%s", p.baseURL(), neturl.PathEscape(videoID), neturl.PathEscape(lang))
+	resp, err := p.fetch(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
+	}
+	defer logging.CloseBody(p.log(), resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w for video %s in language %s", ErrSubtitleNotFound, videoID, lang)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch subtitles: bad status: %s", resp.Status)
+	}
+
+	rawResp, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitles: %w", err)
+	}
+	p.storeRawResponse("subtitle_"+videoID+"_"+lang, rawResp)
+
+	var result struct {
+		Captions []subtitleCue `json:"captions"`
+	}
+	if err := json.Unmarshal(rawResp, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode subtitles: %w", err)
+	}
+	if len(result.Captions) == 0 {
+		return nil, fmt.Errorf("%w for video %s in language %s", ErrSubtitleNotFound, videoID, lang)
+	}
+	return formatSRT(result.Captions), nil
+}
+
+// formatSRT renders cues as SubRip text, numbering them from 1
+func formatSRT(cues []subtitleCue) []byte {
+	var buf bytes.Buffer
+	for i, cue := range cues {
+		start := time.Duration(cue.StartTime) * time.Millisecond
+		end := start + time.Duration(cue.Duration)*time.Millisecond
+		fmt.Fprintf(&buf, "%d\n%s --> %s\n%s\n\n", i+1, srtTimestamp(start), srtTimestamp(end), strings.TrimSpace(cue.Content))
+	}
+	return buf.Bytes()
+}
+
+// srtTimestamp formats d as HH:MM:SS,mmm
+func srtTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSubtitle(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/talks/subtitles/id/399/lang/en":
+			_, _ = w.Write([]byte(`{"captions": [
+				{"content": "Hello.", "startTime": 0, "duration": 2500, "startOfParagraph": true},
+				{"content": " Second\nline ", "startTime": 3723004, "duration": 1000, "startOfParagraph": false}
+			]}`))
+		case "/talks/subtitles/id/399/lang/fr":
+			_, _ = w.Write([]byte(`{"captions": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.BaseURL = mockServer.URL
+
+	srt, err := p.GetSubtitle("399", "en")
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:02,500\nHello.\n\n"+
+		"2\n01:02:03,004 --> 01:02:04,004\nSecond\nline\n\n", string(srt))
+
+	_, err = p.GetSubtitle("399", "fr")
+	assert.ErrorIs(t, err, ErrSubtitleNotFound)
+	_, err = p.GetSubtitle("399", "de")
+	assert.ErrorIs(t, err, ErrSubtitleNotFound)
+}
+
+func TestSRTTimestamp(t *testing.T) {
+	assert.Equal(t, "00:00:00,000", srtTimestamp(0))
+	assert.Equal(t, "00:01:05,250", srtTimestamp(65250*time.Millisecond))
+	assert.Equal(t, "10:00:00,001", srtTimestamp(10*time.Hour+time.Millisecond))
+}
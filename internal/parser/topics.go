@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Topic is a TED topic that can be passed to ParseTopic
+type Topic struct {
+	Slug string `json:"slug"` // e.g. "artificial+intelligence"
+	Name string `json:"name"` // e.g. "Artificial intelligence"
+}
+
+// ListTopics fetches TED's topics page and returns every topic, sorted by slug
+func (p *Parser) ListTopics() ([]Topic, error) {
+	return p.ListTopicsContext(context.Background())
+}
+
+// ListTopicsContext is like ListTopics but aborts when ctx is cancelled
+func (p *Parser) ListTopicsContext(ctx context.Context) ([]Topic, error) {
+	doc, err := p.fetchDocument(ctx, p.baseURL()+"/topics")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch topics page: %w", err)
+	}
+
+	var topics []Topic
+	seen := make(map[string]bool)
+	doc.Find(`a[href*="/topics/"]`).Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		slug := topicSlug(href)
+		name := strings.Join(strings.Fields(s.Text()), " ")
+		if slug == "" || name == "" || seen[slug] {
+			return
+		}
+		seen[slug] = true
+		topics = append(topics, Topic{Slug: slug, Name: name})
+	})
+
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics found")
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Slug < topics[j].Slug })
+	return topics, nil
+}
+
+// topicSlug returns the path segment following /topics/ in href, or "" otherwise
+func topicSlug(href string) string {
+	u := strings.SplitN(strings.SplitN(href, "?", 2)[0], "#", 2)[0]
+	idx := strings.Index(u, "/topics/")
+	if idx == -1 {
+		return ""
+	}
+	slug := strings.SplitN(u[idx+len("/topics/"):], "/", 2)[0]
+	// Keep spaces as "+", the way ParseTopic expects them
+	if unescaped, err := url.PathUnescape(slug); err == nil {
+		slug = strings.ReplaceAll(unescaped, " ", "+")
+	}
+	return strings.ToLower(slug)
+}
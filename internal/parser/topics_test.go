@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTopics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics", r.URL.Path)
+		_, _ = w.Write([]byte(`
+		<nav><a href="/topics">All topics</a></nav>
+		<ul>
+			<li><a href="/topics/technology">Technology</a></li>
+			<li><a href="/topics/artificial+intelligence">Artificial
+				intelligence</a></li>
+			<li><a href="https://www.ted.com/topics/climate%20change?sort=new">Climate change</a></li>
+			<li><a href="/topics/technology">Technology</a></li>
+		</ul>`))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+
+	topics, err := p.ListTopics()
+	assert.NoError(t, err)
+	assert.Equal(t, []Topic{
+		{Slug: "artificial+intelligence", Name: "Artificial intelligence"},
+		{Slug: "climate+change", Name: "Climate change"},
+		{Slug: "technology", Name: "Technology"},
+	}, topics)
+}
+
+func TestListTopics_Empty(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><h1>Topics</h1></html>`))
+	}))
+	defer server.Close()
+
+	p := NewWithClient(server.Client())
+	p.BaseURL = server.URL
+
+	_, err := p.ListTopics()
+	assert.Error(t, err)
+}
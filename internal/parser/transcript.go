@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TranscriptCue represents a single timestamped line of a talk transcript
+type TranscriptCue struct {
+	Time time.Duration `json:"time"` // Offset from the start of the talk
+	Text string        `json:"text"`
+}
+
+const transcriptQuery = `query Transcript($id: ID!, $language: String!) {
+	translation(videoId: $id, language: $language) {
+		paragraphs {
+			cues {
+				text
+				time
+			}
+		}
+	}
+}`
+
+// GetTranscript fetches the transcript of a talk in the given language
+func (p *Parser) GetTranscript(slug, lang string) ([]TranscriptCue, error) {
+	return p.GetTranscriptContext(context.Background(), slug, lang)
+}
+
+// GetTranscriptContext is like GetTranscript but aborts when ctx is cancelled.
+// If the talk has no transcript in lang, it returns an empty slice and ErrTranscriptNotFound.
+func (p *Parser) GetTranscriptContext(ctx context.Context, slug, lang string) ([]TranscriptCue, error) {
+	rawResp, err := p.queryGraphQL(ctx, "Transcript", transcriptQuery, map[string]interface{}{
+		"id":       slug,
+		"language": lang,
+	}, fmt.Sprintf("%s/talks/%s/transcript", p.baseURL(), slug))
+	if err != nil {
+		return nil, err
+	}
+	p.storeRawResponse("transcript_"+slug+"_"+lang, rawResp)
+
+	var result struct {
+		Data struct {
+			Translation *struct {
+				Paragraphs []struct {
+					Cues []struct {
+						Text string `json:"text"`
+						Time int64  `json:"time"` // milliseconds
+					} `json:"cues"`
+				} `json:"paragraphs"`
+			} `json:"translation"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(rawResp, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	cues := []TranscriptCue{}
+	if result.Data.Translation == nil {
+		return cues, fmt.Errorf("%w for %s in language %s", ErrTranscriptNotFound, slug, lang)
+	}
+
+	for _, paragraph := range result.Data.Translation.Paragraphs {
+		for _, cue := range paragraph.Cues {
+			cues = append(cues, TranscriptCue{
+				Time: time.Duration(cue.Time) * time.Millisecond,
+				Text: strings.TrimSpace(cue.Text),
+			})
+		}
+	}
+
+	if len(cues) == 0 {
+		return cues, fmt.Errorf("%w for %s in language %s", ErrTranscriptNotFound, slug, lang)
+	}
+
+	return cues, nil
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTranscript(t *testing.T) {
+	graphqlJSON := []byte(`{
+		"data": {
+			"translation": {
+				"paragraphs": [
+					{
+						"cues": [
+							{"text": "So, I'll start with this:", "time": 1000},
+							{"text": "a couple years ago,", "time": 4500}
+						]
+					},
+					{
+						"cues": [
+							{"text": " an event planner called me ", "time": 65250}
+						]
+					}
+				]
+			}
+		}
+	}`)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Transcript", body.OperationName)
+		assert.Equal(t, "test_slug", body.Variables["id"])
+		assert.Equal(t, "en", body.Variables["language"])
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(graphqlJSON)
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	cues, err := p.GetTranscript("test_slug", "en")
+	assert.NoError(t, err)
+	assert.Equal(t, []TranscriptCue{
+		{Time: time.Second, Text: "So, I'll start with this:"},
+		{Time: 4500 * time.Millisecond, Text: "a couple years ago,"},
+		{Time: 65250 * time.Millisecond, Text: "an event planner called me"},
+	}, cues)
+}
+
+func TestGetTranscript_NotFound(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"translation": null}}`))
+	}))
+	defer mockServer.Close()
+
+	p := NewWithClient(mockServer.Client())
+	p.GraphqlURL = mockServer.URL + "/graphql"
+
+	cues, err := p.GetTranscript("test_slug", "xx")
+	assert.True(t, errors.Is(err, ErrTranscriptNotFound))
+	assert.NotNil(t, cues)
+	assert.Empty(t, cues)
+}
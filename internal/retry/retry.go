@@ -0,0 +1,83 @@
+// Package retry holds the backoff shared by the parser and the downloader
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxDelay caps every wait between two attempts, including one asked for
+// with Retry-After, so a server can't stall a download indefinitely
+const MaxDelay = 30 * time.Second
+
+// Retryable reports whether a response status is worth retrying
+func Retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// Delay returns the exponential backoff with jitter before retry number
+// attempt (0-based): base, doubled on each attempt, plus up to 50%, capped
+// at MaxDelay
+func Delay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := base
+	for i := 0; i < attempt && delay < MaxDelay; i++ {
+		delay *= 2
+	}
+	// Add up to 50% jitter so concurrent clients don't retry in lockstep
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	if delay > MaxDelay {
+		return MaxDelay
+	}
+	return delay
+}
+
+// After parses the Retry-After header of a 429 or 503 response, which is
+// either seconds or an HTTP date, capped at MaxDelay
+func After(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		if seconds > int(MaxDelay/time.Second) {
+			return MaxDelay, true
+		}
+		delay = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		delay = time.Until(t)
+	} else {
+		return 0, false
+	}
+	switch {
+	case delay < 0:
+		return 0, true
+	case delay > MaxDelay:
+		return MaxDelay, true
+	}
+	return delay, true
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
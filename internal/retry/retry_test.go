@@ -0,0 +1,69 @@
+package retry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, Retryable(http.StatusTooManyRequests))
+	assert.True(t, Retryable(http.StatusServiceUnavailable))
+	assert.False(t, Retryable(http.StatusNotFound))
+	assert.False(t, Retryable(http.StatusOK))
+}
+
+func TestDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 0; attempt < 4; attempt++ {
+		backoff := base << attempt
+		delay := Delay(base, attempt)
+		assert.GreaterOrEqual(t, delay, backoff)
+		assert.LessOrEqual(t, delay, backoff+backoff/2)
+	}
+
+	// The backoff stops growing at MaxDelay
+	assert.Equal(t, MaxDelay, Delay(base, 20))
+	assert.Equal(t, MaxDelay, Delay(time.Second, 1000))
+	assert.Equal(t, time.Duration(0), Delay(0, 3))
+}
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		status int
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{http.StatusTooManyRequests, "3", 3 * time.Second, true},
+		{http.StatusServiceUnavailable, "0", 0, true},
+		{http.StatusServiceUnavailable, "", 0, false},
+		{http.StatusServiceUnavailable, "soon", 0, false},
+		{http.StatusInternalServerError, "3", 0, false},
+		{http.StatusTooManyRequests, "3600", MaxDelay, true},
+		{http.StatusTooManyRequests, "99999999999999999", MaxDelay, true},
+		{http.StatusTooManyRequests, time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+		{http.StatusTooManyRequests, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), MaxDelay, true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		got, ok := After(resp)
+		assert.Equal(t, tt.ok, ok, "%d %q", tt.status, tt.header)
+		assert.Equal(t, tt.want, got, "%d %q", tt.status, tt.header)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	assert.NoError(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, Sleep(ctx, time.Hour), context.Canceled)
+	assert.ErrorIs(t, Sleep(ctx, 0), context.Canceled)
+}